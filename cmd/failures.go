@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+	"go.uber.org/zap"
+)
+
+// ErrPartialReport is returned once a report has been written with
+// --continue-on-error but some repositories or apps could not be collected.
+var ErrPartialReport = errors.New("report is partial")
+
+// failure is a single request that could not be collected.
+type failure struct {
+	repository string
+	app        string
+	endpoint   string
+	statusCode int
+	message    string
+}
+
+// failureLog decides how collection proceeds after a getter fails and
+// records the failures that were skipped because of --continue-on-error.
+type failureLog struct {
+	continueOnError bool
+
+	mu       sync.Mutex
+	failures []failure
+}
+
+func newFailureLog(continueOnError bool) *failureLog {
+	return &failureLog{continueOnError: continueOnError}
+}
+
+// skip decides how collection proceeds after a getter fails. It returns
+// nil when err is nil or the failure can be skipped, leaving the caller with
+// an empty list. A resource that does not exist, such as a repository
+// without Codespaces enabled, is logged and skipped, unless it is a
+// repository named on the command line. Forbidden, rate limited
+// and server errors have already been retried by the transport where that is
+// safe, so they abort the run unless --continue-on-error is set, in which
+// case they are recorded and skipped.
+func (f *failureLog) skip(repository string, app string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, data.ErrNotFound) && app != "Repository" {
+		zap.S().Warnf("Skipping missing resource: %v", err)
+		return nil
+	}
+	if !f.continueOnError {
+		return err
+	}
+
+	f.record(repository, app, err)
+	return nil
+}
+
+// record adds err to the log so it is reported in the error file.
+func (f *failureLog) record(repository string, app string, err error) {
+	entry := failure{
+		repository: repository,
+		app:        app,
+		message:    err.Error(),
+	}
+	var apiErr *data.APIError
+	if errors.As(err, &apiErr) {
+		entry.endpoint = apiErr.Endpoint
+		entry.statusCode = apiErr.StatusCode
+		entry.message = apiErr.Message
+	}
+
+	zap.S().Warnf("Continuing after error collecting %s for %s: %v", app, repository, err)
+
+	f.mu.Lock()
+	f.failures = append(f.failures, entry)
+	f.mu.Unlock()
+}
+
+// report writes recorded failures to errorWriter, which was opened for path,
+// and returns ErrPartialReport if there were any. Nothing is committed when
+// every request succeeded.
+func (f *failureLog) report(errorWriter reportOutput, path string) error {
+	if len(f.failures) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(f.failures, func(i, j int) bool {
+		if f.failures[i].repository != f.failures[j].repository {
+			return f.failures[i].repository < f.failures[j].repository
+		}
+		if f.failures[i].app != f.failures[j].app {
+			return f.failures[i].app < f.failures[j].app
+		}
+		return f.failures[i].endpoint < f.failures[j].endpoint
+	})
+
+	csvWriter := csv.NewWriter(errorWriter)
+
+	err := csvWriter.Write([]string{
+		"RepositoryName",
+		"App",
+		"Endpoint",
+		"StatusCode",
+		"Message",
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range f.failures {
+		statusCode := ""
+		if entry.statusCode != 0 {
+			statusCode = strconv.Itoa(entry.statusCode)
+		}
+		err = csvWriter.Write([]string{
+			entry.repository,
+			entry.app,
+			entry.endpoint,
+			statusCode,
+			entry.message,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		return err
+	}
+	if err = errorWriter.Commit(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: %d request(s) failed, see %s", ErrPartialReport, len(f.failures), path)
+}
+
+// openErrorOutput opens the file failures are written to when
+// --continue-on-error is set, so that a file that cannot be written fails
+// the run before any request is made. It returns nil otherwise.
+func openErrorOutput(cmdFlags *cmdFlags, reportFile string, defaultName string) (reportOutput, error) {
+	if !cmdFlags.continueOnError {
+		return nil, nil
+	}
+	if cmdFlags.errorFile == "" {
+		cmdFlags.errorFile = errorFilePath(reportFile, defaultName)
+	}
+	return openReportOutput(cmdFlags.errorFile, cmdFlags.force)
+}
+
+// errorFilePath derives the error file name from the report file name.
+func errorFilePath(reportFile string, defaultName string) string {
+	return derivedFilePath(reportFile, "errors", defaultName)
+}
+
+// derivedFilePath names a CSV file written alongside the report, such as
+// report-errors.csv, or returns defaultName when the report goes to stdout.
+func derivedFilePath(reportFile string, suffix string, defaultName string) string {
+	if reportFile == stdoutPath {
+		return defaultName
+	}
+	return strings.TrimSuffix(reportFile, filepath.Ext(reportFile)) + "-" + suffix + ".csv"
+}
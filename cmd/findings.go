@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"strconv"
+	"sync"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+)
+
+// findingLog records the findings and secret flows reported while scanning
+// usage so they can be written to their files once the report is complete.
+type findingLog struct {
+	mu       sync.Mutex
+	findings []data.Finding
+	flows    []data.SecretFlow
+}
+
+func newFindingLog() *findingLog {
+	return &findingLog{}
+}
+
+func (f *findingLog) add(finding data.Finding) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.findings = append(f.findings, finding)
+	return nil
+}
+
+func (f *findingLog) addFlow(flow data.SecretFlow) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flows = append(f.flows, flow)
+	return nil
+}
+
+// report writes recorded findings to findingsWriter and returns how many
+// there were. Nothing is committed when there are no findings.
+func (f *findingLog) report(findingsWriter reportOutput) (int, error) {
+	if len(f.findings) == 0 {
+		return 0, nil
+	}
+
+	csvWriter := csv.NewWriter(findingsWriter)
+
+	err := csvWriter.Write([]string{
+		"Finding",
+		"RepositoryName",
+		"Path",
+		"Line",
+		"SecretType",
+		"SecretName",
+		"Message",
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, finding := range f.findings {
+		line := ""
+		if finding.Line != 0 {
+			line = strconv.Itoa(finding.Line)
+		}
+		err = csvWriter.Write([]string{
+			finding.Kind,
+			finding.RepositoryName,
+			finding.Path,
+			line,
+			finding.SecretType,
+			finding.SecretName,
+			finding.Message,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		return 0, err
+	}
+	if err = findingsWriter.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(f.findings), nil
+}
+
+// reportFlows writes recorded secret flows to flowsWriter and returns how
+// many there were. Nothing is committed when there are no flows.
+func (f *findingLog) reportFlows(flowsWriter reportOutput) (int, error) {
+	if len(f.flows) == 0 {
+		return 0, nil
+	}
+
+	csvWriter := csv.NewWriter(flowsWriter)
+
+	err := csvWriter.Write([]string{
+		"RepositoryName",
+		"SecretName",
+		"CallerRepository",
+		"CallerPath",
+		"Line",
+		"CalleeRepository",
+		"CalleeWorkflow",
+		"CalleeRef",
+		"PassedAs",
+		"Inherited",
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, flow := range f.flows {
+		line := ""
+		if flow.Line != 0 {
+			line = strconv.Itoa(flow.Line)
+		}
+		err = csvWriter.Write([]string{
+			flow.RepositoryName,
+			flow.SecretName,
+			flow.CallerRepository,
+			flow.CallerPath,
+			line,
+			flow.CalleeRepository,
+			flow.CalleeWorkflow,
+			flow.CalleeRef,
+			flow.PassedAs,
+			strconv.FormatBool(flow.Inherited),
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		return 0, err
+	}
+	if err = flowsWriter.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(f.flows), nil
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/katiem0/gh-export-secrets/internal/report"
+)
+
+// stdoutPath is the --output-file value that writes a report to stdout.
+const stdoutPath = "-"
+
+// reportOutput is the destination of a report. Commit is called once the
+// report is complete and Close is always called, discarding uncommitted output.
+type reportOutput interface {
+	io.Writer
+	Commit() error
+	Close() error
+}
+
+// openReportOutput returns stdout for "-", otherwise a report file that is
+// renamed into place on Commit.
+func openReportOutput(path string, force bool) (reportOutput, error) {
+	if path == stdoutPath {
+		return stdoutOutput{os.Stdout}, nil
+	}
+	return report.CreateFile(path, force)
+}
+
+type stdoutOutput struct {
+	io.Writer
+}
+
+func (stdoutOutput) Commit() error {
+	return nil
+}
+
+func (stdoutOutput) Close() error {
+	return nil
+}
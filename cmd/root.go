@@ -1,100 +1,198 @@
 package cmd
 
 import (
-	"encoding/csv"
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
 	"io"
-	"os"
-	"strconv"
+	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/cli/go-gh/v2/pkg/api"
 	"github.com/cli/go-gh/v2/pkg/auth"
 	"github.com/katiem0/gh-export-secrets/internal/data"
 	"github.com/katiem0/gh-export-secrets/internal/log"
+	"github.com/katiem0/gh-export-secrets/internal/report"
+	"github.com/katiem0/gh-export-secrets/pkg/inventory"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
 type cmdFlags struct {
-	app        string
-	hostname   string
-	token      string
-	reportFile string
-	debug      bool
+	apps            []string
+	hostname        string
+	token           string
+	reportFile      string
+	format          string
+	includeValues   bool
+	scanUsage       bool
+	findingsFile    string
+	flowsFile       string
+	force           bool
+	concurrency     int
+	continueOnError bool
+	errorFile       string
+	record          string
+	replay          string
+	updatedBefore   string
+	updatedSince    string
+	debug           bool
+
+	updatedBeforeDate time.Time
+	updatedSinceDate  time.Time
+	appSet            inventory.AppSet
+}
+
+// completeApps completes the last entry of a comma separated --app value,
+// leaving out apps that are already listed.
+func completeApps(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefix := toComplete[:strings.LastIndex(toComplete, ",")+1]
+	selected := strings.Split(prefix, ",")
+
+	var completions []string
+	for _, name := range inventory.AppNames() {
+		if !slices.Contains(selected, name) {
+			completions = append(completions, prefix+name)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// parseDateFlag accepts either a date (2006-01-02) or an RFC 3339 timestamp.
+func parseDateFlag(name string, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --%s %q: expected YYYY-MM-DD or RFC 3339 timestamp", name, value)
+	}
+	return t, nil
 }
 
 func NewCmd() *cobra.Command {
 	//var repository string
 	cmdFlags := cmdFlags{}
-	var authToken string
 
 	cmd := cobra.Command{
 		Use:   "gh export-secrets [flags] <organization> [repo ...] ",
-		Short: "Generate a report of Actions, Dependabot, and Codespaces secrets for an organization and/or repositories.",
-		Long:  "Generate a report of Actions, Dependabot, and Codespaces secrets for an organization and/or repositories.",
+		Short: "Generate a report of Actions, Dependabot, Codespaces, and Environment secrets and Actions variables for an organization and/or repositories.",
+		Long:  "Generate a report of Actions, Dependabot, Codespaces, and Environment secrets and Actions variables for an organization and/or repositories.",
 		Args:  cobra.MinimumNArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			var err error
-			var gqlClient *api.GraphQLClient
-			var restClient *api.RESTClient
 
 			// Reinitialize logging if debugging was enabled
 			if cmdFlags.debug {
 				logger, _ := log.NewLogger(cmdFlags.debug)
-				defer logger.Sync() // nolint:errcheck
 				zap.ReplaceGlobals(logger)
 			}
 
-			if cmdFlags.token != "" {
-				authToken = cmdFlags.token
-			} else {
-				t, _ := auth.TokenForHost(cmdFlags.hostname)
-				authToken = t
+			cmdFlags.appSet, err = inventory.ParseAppSet(cmdFlags.apps...)
+			if err != nil {
+				return fmt.Errorf("invalid --app: %w", err)
 			}
 
-			gqlClient, err = api.NewGraphQLClient(api.ClientOptions{
-				Headers: map[string]string{
-					"Accept": "application/vnd.github.hawkgirl-preview+json",
-				},
-				Host:      cmdFlags.hostname,
-				AuthToken: authToken,
-			})
-
+			cmdFlags.updatedBeforeDate, err = parseDateFlag("updated-before", cmdFlags.updatedBefore)
 			if err != nil {
-				zap.S().Errorf("Error arose retrieving graphql client")
 				return err
 			}
+			cmdFlags.updatedSinceDate, err = parseDateFlag("updated-since", cmdFlags.updatedSince)
+			return err
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			zap.L().Sync() // nolint:errcheck
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Arguments are valid, so further errors should not print usage
+			cmd.SilenceUsage = true
 
-			restClient, err = api.NewRESTClient(api.ClientOptions{
-				Headers: map[string]string{
-					"Accept": "application/vnd.github+json",
-				},
-				Host:      cmdFlags.hostname,
-				AuthToken: authToken,
-			})
-
+			g, err := newAPIGetter(&cmdFlags)
 			if err != nil {
-				zap.S().Errorf("Error arose retrieving rest client")
 				return err
 			}
 
 			owner := args[0]
 			repos := args[1:]
 
-			if _, err := os.Stat(cmdFlags.reportFile); errors.Is(err, os.ErrExist) {
+			if err = report.ValidateFormat(cmdFlags.format); err != nil {
 				return err
 			}
 
-			reportWriter, err := os.OpenFile(cmdFlags.reportFile, os.O_WRONLY|os.O_CREATE, 0644)
+			if !cmd.Flags().Changed("output-file") && cmdFlags.reportFile != stdoutPath {
+				cmdFlags.reportFile = fmt.Sprintf("%s.%s", strings.TrimSuffix(cmdFlags.reportFile, ".csv"), report.Extension(cmdFlags.format))
+			}
+
+			// Write to stdout when requested so the report can be piped; logs stay on stderr
+			reportWriter, err := openReportOutput(cmdFlags.reportFile, cmdFlags.force)
+			if err != nil {
+				return err
+			}
+			defer reportWriter.Close() // nolint:errcheck
+
+			// Open every file written alongside the report before collecting, so
+			// one that already exists fails the run before a partial report is
+			// committed
+			timestamp := time.Now().Format("20060102150405")
+			var findingsWriter, flowsWriter reportOutput
+			if cmdFlags.scanUsage {
+				if cmdFlags.findingsFile == "" {
+					cmdFlags.findingsFile = derivedFilePath(cmdFlags.reportFile, "findings", fmt.Sprintf("findings-%s.csv", timestamp))
+				}
+				if findingsWriter, err = openReportOutput(cmdFlags.findingsFile, cmdFlags.force); err != nil {
+					return err
+				}
+				defer findingsWriter.Close() // nolint:errcheck
 
+				if cmdFlags.flowsFile == "" {
+					cmdFlags.flowsFile = derivedFilePath(cmdFlags.reportFile, "secret-flows", fmt.Sprintf("secret-flows-%s.csv", timestamp))
+				}
+				if flowsWriter, err = openReportOutput(cmdFlags.flowsFile, cmdFlags.force); err != nil {
+					return err
+				}
+				defer flowsWriter.Close() // nolint:errcheck
+			}
+			errorWriter, err := openErrorOutput(&cmdFlags, cmdFlags.reportFile, fmt.Sprintf("errors-%s.csv", timestamp))
 			if err != nil {
 				return err
 			}
+			if errorWriter != nil {
+				defer errorWriter.Close() // nolint:errcheck
+			}
+
+			failures := newFailureLog(cmdFlags.continueOnError)
+			findings := newFindingLog()
+			if err = runCmd(cmd.Context(), owner, repos, &cmdFlags, g, failures, findings, reportWriter); err != nil {
+				return err
+			}
+
+			if err = reportWriter.Commit(); err != nil {
+				return err
+			}
+
+			if cmdFlags.scanUsage {
+				count, err := findings.report(findingsWriter)
+				if err != nil {
+					return err
+				}
+				if count > 0 {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%d finding(s) written to %s\n", count, cmdFlags.findingsFile)
+				}
 
-			return runCmd(owner, repos, &cmdFlags, data.NewAPIGetter(gqlClient, restClient), reportWriter)
+				count, err = findings.reportFlows(flowsWriter)
+				if err != nil {
+					return err
+				}
+				if count > 0 {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%d secret flow(s) written to %s\n", count, cmdFlags.flowsFile)
+				}
+			}
+
+			return failures.report(errorWriter, cmdFlags.errorFile)
 		},
 	}
 
@@ -103,378 +201,129 @@ func NewCmd() *cobra.Command {
 
 	// Configure flags for command
 
-	cmd.PersistentFlags().StringVarP(&cmdFlags.app, "app", "a", "actions", "List secrets for a specific application or all: {all|actions|codespaces|dependabot}")
+	cmd.PersistentFlags().StringSliceVarP(&cmdFlags.apps, "app", "a", []string{"actions"}, fmt.Sprintf("List secrets for one or more applications, comma separated or repeated: {%s}", strings.Join(inventory.AppNames(), "|")))
 	cmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
 	cmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
-	cmd.Flags().StringVarP(&cmdFlags.reportFile, "output-file", "o", reportFileDefault, "Name of file to write CSV report")
+	cmd.Flags().StringVarP(&cmdFlags.reportFile, "output-file", "o", reportFileDefault, "Name of file to write the report, or - for stdout")
+	cmd.Flags().StringVarP(&cmdFlags.format, "format", "f", "csv", fmt.Sprintf("Report output format: {%s}", strings.Join(report.Formats, "|")))
+	cmd.PersistentFlags().IntVarP(&cmdFlags.concurrency, "concurrency", "c", 1, "Number of repositories to collect secrets for concurrently")
+	cmd.PersistentFlags().BoolVarP(&cmdFlags.continueOnError, "continue-on-error", "", false, "Keep collecting after API errors and record them in an error file; exits with status 2 if the report is partial")
+	cmd.PersistentFlags().StringVarP(&cmdFlags.errorFile, "error-file", "", "", "Name of file to write errors recorded by --continue-on-error (default \"<output-file>-errors.csv\")")
+	cmd.PersistentFlags().BoolVarP(&cmdFlags.force, "force", "", false, "Overwrite report files that already exist")
+	cmd.Flags().BoolVarP(&cmdFlags.scanUsage, "scan-usage", "", false, "Scan the workflows, Dependabot and dev container configuration of each repository for the secrets and variables they reference, adding Referenced, ReferencedBy and IndirectConsumers columns")
+	cmd.Flags().StringVarP(&cmdFlags.findingsFile, "findings-file", "", "", "Name of file to write --scan-usage findings, such as secrets referenced by a workflow that the repository cannot read (default \"<output-file>-findings.csv\")")
+	cmd.Flags().StringVarP(&cmdFlags.flowsFile, "secret-flows-file", "", "", "Name of file to write the secrets --scan-usage finds passed to reusable workflows (default \"<output-file>-secret-flows.csv\")")
+	cmd.PersistentFlags().BoolVarP(&cmdFlags.includeValues, "include-values", "", false, "Include the values of Actions variables in the report")
+	cmd.PersistentFlags().StringVarP(&cmdFlags.record, "record", "", "", "Directory to save every API response to, with Authorization headers removed, for later use with --replay")
+	cmd.PersistentFlags().StringVarP(&cmdFlags.replay, "replay", "", "", "Directory of responses saved by --record to answer API requests from instead of the network")
+	cmd.PersistentFlags().StringVarP(&cmdFlags.updatedBefore, "updated-before", "", "", "Only report secrets last updated before this date (YYYY-MM-DD or RFC 3339)")
+	cmd.PersistentFlags().StringVarP(&cmdFlags.updatedSince, "updated-since", "", "", "Only report secrets last updated on or after this date (YYYY-MM-DD or RFC 3339)")
 	cmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
 	//cmd.MarkPersistentFlagRequired("app")
+	cmd.MarkFlagsMutuallyExclusive("record", "replay")
+	cmd.RegisterFlagCompletionFunc("app", completeApps) // nolint:errcheck
+
+	cmd.AddCommand(newStaleCmd(&cmdFlags))
 
 	return &cmd
 }
 
-func runCmd(owner string, repos []string, cmdFlags *cmdFlags, g *data.APIGetter, reportWriter io.Writer) error {
-	var reposCursor *string
-	var allRepos []data.RepoInfo
-
-	csvWriter := csv.NewWriter(reportWriter)
-
-	err := csvWriter.Write([]string{
-		"SecretLevel",
-		"SecretType",
-		"SecretName",
-		"SecretAccess",
-		"RepositoryName",
-		"RepositoryID",
-	})
-
-	if err != nil {
-		return err
-	}
-
-	if len(repos) > 0 {
-		zap.S().Infof("Processing repos: %s", repos)
-
-		for _, repo := range repos {
-
-			zap.S().Debugf("Processing %s/%s", owner, repo)
+// newAPIGetter builds the GraphQL and REST clients for the configured host and token.
+func newAPIGetter(cmdFlags *cmdFlags) (*data.APIGetter, error) {
+	var authToken string
+	var err error
 
-			repoQuery, err := g.GetRepo(owner, repo)
-			if err != nil {
-				return err
-			}
-			allRepos = append(allRepos, repoQuery.Repository)
-		}
+	// Share one transport so concurrent workers all observe the same rate limit
+	var transport http.RoundTripper = data.NewRateLimitTransport(http.DefaultTransport)
 
+	if cmdFlags.token != "" {
+		authToken = cmdFlags.token
 	} else {
-		// Prepare writer for outputting report
-		for {
-			zap.S().Debugf("Processing list of repositories for %s", owner)
-			reposQuery, err := g.GetReposList(owner, reposCursor)
-
-			if err != nil {
-				return err
-			}
-
-			allRepos = append(allRepos, reposQuery.Organization.Repositories.Nodes...)
-
-			reposCursor = &reposQuery.Organization.Repositories.PageInfo.EndCursor
-
-			if !reposQuery.Organization.Repositories.PageInfo.HasNextPage {
-				break
-			}
-		}
+		t, _ := auth.TokenForHost(cmdFlags.hostname)
+		authToken = t
 	}
 
-	// Writing to CSV Org level Actions secrets
-	if len(repos) == 0 && (cmdFlags.app == "all" || cmdFlags.app == "actions") {
-		orgSecrets, err := g.GetOrgActionSecrets(owner)
-		if err != nil {
-			return err
-		}
-
-		var oActionResponseObject data.SecretsResponse
-		err = json.Unmarshal(orgSecrets, &oActionResponseObject)
-		if err != nil {
-			return err
-		}
-		if len(oActionResponseObject.Secrets) == 0 {
-			zap.S().Debugf("No org level Actions Secrets for %s", owner)
-		} else {
-			zap.S().Debugf("Gathering Actions Secrets for %s", owner)
-		}
-		for _, orgSecret := range oActionResponseObject.Secrets {
-			switch orgSecret.Visibility {
-			case "selected":
-				zap.S().Debugf("Gathering Actions Secrets for %s that are scoped to specific repositories", owner)
-				scoped_repo, err := g.GetScopedOrgActionSecrets(owner, orgSecret.Name)
-				if err != nil {
-					zap.S().Error("Error raised in writing output", zap.Error(err))
-				}
-				var responseOObject data.ScopedSecretsResponse
-				err = json.Unmarshal(scoped_repo, &responseOObject)
-				if err != nil {
-					return err
-				}
-				for _, scopeSecret := range responseOObject.Repositories {
-					err = csvWriter.Write([]string{
-						"Organization",
-						"Actions",
-						orgSecret.Name,
-						orgSecret.Visibility,
-						scopeSecret.Name,
-						strconv.Itoa(scopeSecret.ID),
-					})
-					if err != nil {
-						zap.S().Error("Error raised in writing output", zap.Error(err))
-					}
-				}
-			case "private":
-				zap.S().Debugf("Gathering Actions Secret %s for %s that is accessible to all internal and private repositories.", orgSecret.Name, owner)
-				for _, repoActPrivateSecret := range allRepos {
-					if repoActPrivateSecret.Visibility != "public" {
-						err = csvWriter.Write([]string{
-							"Organization",
-							"Actions",
-							orgSecret.Name,
-							orgSecret.Visibility,
-							repoActPrivateSecret.Name,
-							strconv.Itoa(repoActPrivateSecret.DatabaseId),
-						})
-						if err != nil {
-							zap.S().Error("Error raised in writing output", zap.Error(err))
-						}
-					}
-				}
-			default:
-				zap.S().Debugf("Gathering public Actions Secret %s for %s", orgSecret.Name, owner)
-				err = csvWriter.Write([]string{
-					"Organization",
-					"Actions",
-					orgSecret.Name,
-					orgSecret.Visibility,
-				})
-				if err != nil {
-					zap.S().Error("Error raised in writing output", zap.Error(err))
-				}
-			}
+	switch {
+	case cmdFlags.replay != "":
+		// Replayed runs never reach the network, so no token is required
+		transport, err = data.NewReplayTransport(cmdFlags.replay)
+		if authToken == "" {
+			authToken = "replay"
 		}
+	case cmdFlags.record != "":
+		transport, err = data.NewRecordTransport(transport, cmdFlags.record)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// Writing to CSV Org level Dependabot secrets
-	if len(repos) == 0 && (cmdFlags.app == "all" || cmdFlags.app == "dependabot") {
+	gqlClient, err := api.NewGraphQLClient(api.ClientOptions{
+		Headers: map[string]string{
+			"Accept": "application/vnd.github.hawkgirl-preview+json",
+		},
+		Host:      cmdFlags.hostname,
+		AuthToken: authToken,
+		Transport: transport,
+	})
 
-		orgDepSecrets, err := g.GetOrgDependabotSecrets(owner)
-		if err != nil {
-			return err
-		}
-		var oDepResponseObject data.SecretsResponse
-		err = json.Unmarshal(orgDepSecrets, &oDepResponseObject)
-		if err != nil {
-			return err
-		}
+	if err != nil {
+		zap.S().Errorf("Error arose retrieving graphql client")
+		return nil, err
+	}
 
-		if len(oDepResponseObject.Secrets) == 0 {
-			zap.S().Debugf("No org level Dependabot Secrets for %s", owner)
-		} else {
-			zap.S().Debugf("Gathering Dependabot Secrets for %s", owner)
-		}
+	restClient, err := api.NewRESTClient(api.ClientOptions{
+		Headers: map[string]string{
+			"Accept": "application/vnd.github+json",
+		},
+		Host:      cmdFlags.hostname,
+		AuthToken: authToken,
+		Transport: transport,
+	})
 
-		for _, orgDepSecret := range oDepResponseObject.Secrets {
-			switch orgDepSecret.Visibility {
-			case "selected":
-				zap.S().Debugf("Gathering Dependabot Secret %s for %s that is scoped to specific repositories", orgDepSecret.Name, owner)
-				scoped_repo, err := g.GetScopedOrgDependabotSecrets(owner, orgDepSecret.Name)
-				if err != nil {
-					return err
-				}
-				var rDepResponseObject data.ScopedSecretsResponse
-				err = json.Unmarshal(scoped_repo, &rDepResponseObject)
-				if err != nil {
-					return err
-				}
-				for _, depScopeSecret := range rDepResponseObject.Repositories {
-					err = csvWriter.Write([]string{
-						"Organization",
-						"Dependabot",
-						orgDepSecret.Name,
-						orgDepSecret.Visibility,
-						depScopeSecret.Name,
-						strconv.Itoa(depScopeSecret.ID),
-					})
-					if err != nil {
-						zap.S().Error("Error raised in writing output", zap.Error(err))
-					}
-				}
-			case "private":
-				zap.S().Debugf("Gathering Dependabot Secret %s for %s that is accessible to all internal and private repositories.", orgDepSecret.Name, owner)
-				for _, repoPrivateSecret := range allRepos {
-					if repoPrivateSecret.Visibility != "public" {
-						err = csvWriter.Write([]string{
-							"Organization",
-							"Dependabot",
-							orgDepSecret.Name,
-							orgDepSecret.Visibility,
-							repoPrivateSecret.Name,
-							strconv.Itoa(repoPrivateSecret.DatabaseId),
-						})
-						if err != nil {
-							zap.S().Error("Error raised in writing output", zap.Error(err))
-						}
-					}
-				}
-			default:
-				zap.S().Debugf("Gathering public Dependabot Secret %s for %s", orgDepSecret.Name, owner)
-				err = csvWriter.Write([]string{
-					"Organization",
-					"Dependabot",
-					orgDepSecret.Name,
-					orgDepSecret.Visibility,
-				})
-				if err != nil {
-					zap.S().Error("Error raised in writing output", zap.Error(err))
-				}
-			}
-		}
+	if err != nil {
+		zap.S().Errorf("Error arose retrieving rest client")
+		return nil, err
 	}
 
-	// Writing to CSV Org level Codespaces secrets
-	if len(repos) == 0 && (cmdFlags.app == "all" || cmdFlags.app == "codespaces") {
-
-		orgCodeSecrets, err := g.GetOrgCodespacesSecrets(owner)
-		if err != nil {
-			return err
-		}
-		var oCodeResponseObject data.SecretsResponse
-		err = json.Unmarshal(orgCodeSecrets, &oCodeResponseObject)
-		if err != nil {
-			return err
-		}
-		if len(oCodeResponseObject.Secrets) == 0 {
-			zap.S().Debugf("No org level Codespaces Secrets for %s", owner)
-		} else {
-			zap.S().Debugf("Gathering Codespaces Secrets for %s", owner)
-		}
+	return data.NewAPIGetter(gqlClient, restClient), nil
+}
 
-		for _, orgCodeSecret := range oCodeResponseObject.Secrets {
-			zap.S().Debugf("Gathering Codespaces Secrets for %s that are scoped to specific repositories", owner)
-			switch orgCodeSecret.Visibility {
-			case "selected":
-				scoped_repo, err := g.GetScopedOrgCodespacesSecrets(owner, orgCodeSecret.Name)
-				if err != nil {
-					return err
-				}
-				var rCodeResponseObject data.ScopedSecretsResponse
-				err = json.Unmarshal(scoped_repo, &rCodeResponseObject)
-				if err != nil {
-					return err
-				}
-				for _, codeScopeSecret := range rCodeResponseObject.Repositories {
-					err = csvWriter.Write([]string{
-						"Organization",
-						"Codespaces",
-						orgCodeSecret.Name,
-						orgCodeSecret.Visibility,
-						codeScopeSecret.Name,
-						strconv.Itoa(codeScopeSecret.ID),
-					})
-					if err != nil {
-						zap.S().Error("Error raised in writing output", zap.Error(err))
-					}
-				}
-			case "private":
-				zap.S().Debugf("Gathering Codespaces Secret %s for %s that is accessible to all internal and private repositories.", orgCodeSecret.Name, owner)
-				for _, repoCodePrivateSecret := range allRepos {
-					if repoCodePrivateSecret.Visibility != "public" {
-						err = csvWriter.Write([]string{
-							"Organization",
-							"Codespaces",
-							orgCodeSecret.Name,
-							orgCodeSecret.Visibility,
-							repoCodePrivateSecret.Name,
-							strconv.Itoa(repoCodePrivateSecret.DatabaseId),
-						})
-						if err != nil {
-							zap.S().Error("Error raised in writing output", zap.Error(err))
-						}
-					}
-				}
-			default:
-				zap.S().Debugf("Gathering public Codespaces Secret %s for %s", orgCodeSecret.Name, owner)
-				err = csvWriter.Write([]string{
-					"Organization",
-					"Codespaces",
-					orgCodeSecret.Name,
-					orgCodeSecret.Visibility,
-				})
-				if err != nil {
-					zap.S().Error("Error raised in writing output", zap.Error(err))
-				}
-			}
-		}
+func runCmd(ctx context.Context, owner string, repos []string, cmdFlags *cmdFlags, g data.Getter, failures *failureLog, findings *findingLog, reportWriter io.Writer) error {
+	exportWriter, err := report.NewWriter(cmdFlags.format, reportWriter, report.Options{
+		IncludeValues: cmdFlags.includeValues,
+		IncludeUsage:  cmdFlags.scanUsage,
+	})
+	if err != nil {
+		return err
 	}
 
-	// Writing to CSV repository level Secrets
-	for _, singleRepo := range allRepos {
-		// Writing to CSV repository level Actions secrets
-		if cmdFlags.app == "all" || cmdFlags.app == "actions" {
-			repoActionSecretsList, err := g.GetRepoActionSecrets(owner, singleRepo.Name)
-			if err != nil {
-				return err
-			}
-			var repoActionResponseObject data.SecretsResponse
-			err = json.Unmarshal(repoActionSecretsList, &repoActionResponseObject)
-			if err != nil {
-				return err
-			}
-			for _, repoActionsSecret := range repoActionResponseObject.Secrets {
-				err = csvWriter.Write([]string{
-					"Repository",
-					"Actions",
-					repoActionsSecret.Name,
-					"RepoOnly",
-					singleRepo.Name,
-					strconv.Itoa(singleRepo.DatabaseId),
-				})
-				if err != nil {
-					zap.S().Error("Error raised in writing output", zap.Error(err))
-				}
-			}
-		}
-		// Writing to CSV repository level Dependabot secrets
-		if cmdFlags.app == "all" || cmdFlags.app == "dependabot" {
-			repoDepSecretsList, err := g.GetRepoDependabotSecrets(owner, singleRepo.Name)
-			if err != nil {
-				return err
-			}
-			var repoDepResponseObject data.SecretsResponse
-			err = json.Unmarshal(repoDepSecretsList, &repoDepResponseObject)
-			if err != nil {
-				return err
-			}
-			for _, repoDepSecret := range repoDepResponseObject.Secrets {
-				err = csvWriter.Write([]string{
-					"Repository",
-					"Dependabot",
-					repoDepSecret.Name,
-					"RepoOnly",
-					singleRepo.Name,
-					strconv.Itoa(singleRepo.DatabaseId),
-				})
-				if err != nil {
-					zap.S().Error("Error raised in writing output", zap.Error(err))
-				}
-			}
-		}
-		// Writing to CSV repository level Codespaces secrets
-		if cmdFlags.app == "all" || cmdFlags.app == "codespaces" {
-			repoCodeSecretsList, err := g.GetRepoCodespacesSecrets(owner, singleRepo.Name)
-			if err != nil {
-				zap.S().Error("Error raised in writing output", zap.Error(err))
-			}
-			var repoCodeResponseObject data.SecretsResponse
-			err = json.Unmarshal(repoCodeSecretsList, &repoCodeResponseObject)
-			if err != nil {
-				return err
-			}
-			for _, repoCodeSecret := range repoCodeResponseObject.Secrets {
-				err = csvWriter.Write([]string{
-					"Repository",
-					"Codespaces",
-					repoCodeSecret.Name,
-					"RepoOnly",
-					singleRepo.Name,
-					strconv.Itoa(singleRepo.DatabaseId),
-				})
-				if err != nil {
-					zap.S().Error("Error raised in writing output", zap.Error(err))
-				}
-			}
-		}
+	// A failed write stops collection, so a truncated report is never
+	// committed
+	err = collectSecrets(ctx, owner, repos, cmdFlags, g, failures, findings, exportWriter.Write)
+
+	closeErr := exportWriter.Close()
+	if err != nil {
+		return err
 	}
 
-	csvWriter.Flush()
+	return closeErr
+}
 
-	return nil
+// collectSecrets runs an inventory.Collector configured from the command
+// line flags, passing failed requests to failures and, when usage is
+// scanned, findings and secret flows to findings.
+func collectSecrets(ctx context.Context, owner string, repos []string, cmdFlags *cmdFlags, g data.Getter, failures *failureLog, findings *findingLog, emit func(data.SecretExport) error) error {
+	collector := inventory.NewCollector(owner, repos, cmdFlags.appSet, g)
+	collector.Concurrency = cmdFlags.concurrency
+	collector.IncludeValues = cmdFlags.includeValues
+	collector.ScanUsage = cmdFlags.scanUsage
+	collector.UpdatedBefore = cmdFlags.updatedBeforeDate
+	collector.UpdatedSince = cmdFlags.updatedSinceDate
+	collector.OnError = failures.skip
+	if findings != nil {
+		collector.OnFinding = findings.add
+		collector.OnSecretFlow = findings.addFlow
+	}
 
+	return collector.Collect(ctx, emit)
 }
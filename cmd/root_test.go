@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+	"github.com/katiem0/gh-export-secrets/internal/fakegithub"
+	"github.com/katiem0/gh-export-secrets/pkg/inventory"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func date(month time.Month, day int) time.Time {
+	return time.Date(2024, month, day, 12, 0, 0, 0, time.UTC)
+}
+
+// testOrganization has more of every list than fits on a page when
+// MaxPerPage is 2, and organization secrets of each visibility.
+func testOrganization() *fakegithub.Organization {
+	return &fakegithub.Organization{
+		Login: "acme",
+		Repositories: []fakegithub.Repository{
+			{
+				ID: 101, Name: "web", Visibility: "PUBLIC",
+				ActionsSecrets: []fakegithub.Secret{{Name: "NETLIFY_TOKEN", CreatedAt: date(1, 5), UpdatedAt: date(3, 1)}},
+				Environments: []fakegithub.Environment{
+					{ID: 1, Name: "preview"},
+					{ID: 2, Name: "production", Secrets: []fakegithub.Secret{{Name: "CDN_KEY", CreatedAt: date(2, 1), UpdatedAt: date(2, 1)}}},
+					{ID: 3, Name: "staging", Variables: []fakegithub.Variable{{Name: "BASE_URL", Value: "https://staging.acme.test", CreatedAt: date(2, 2), UpdatedAt: date(2, 3)}}},
+				},
+			},
+			{
+				ID: 102, Name: "api", Visibility: "PRIVATE",
+				ActionsSecrets: []fakegithub.Secret{
+					{Name: "DEPLOY_KEY", CreatedAt: date(1, 1), UpdatedAt: date(1, 1)},
+					{Name: "DB_PASSWORD", CreatedAt: date(1, 2), UpdatedAt: date(4, 2)},
+					{Name: "SENTRY_DSN", CreatedAt: date(1, 3), UpdatedAt: date(1, 3)},
+				},
+				DependabotSecrets: []fakegithub.Secret{{Name: "NPM_TOKEN", CreatedAt: date(1, 4), UpdatedAt: date(1, 4)}},
+				CodespacesSecrets: []fakegithub.Secret{{Name: "DEV_DB_URL", CreatedAt: date(1, 6), UpdatedAt: date(1, 6)}},
+				Variables:         []fakegithub.Variable{{Name: "LOG_LEVEL", Value: "debug", CreatedAt: date(1, 7), UpdatedAt: date(1, 7)}},
+				Environments: []fakegithub.Environment{
+					{ID: 4, Name: "production", Secrets: []fakegithub.Secret{{Name: "PROD_TOKEN", CreatedAt: date(3, 3), UpdatedAt: date(3, 4)}}},
+				},
+			},
+			{ID: 103, Name: "docs", Visibility: "INTERNAL"},
+			{
+				ID: 104, Name: "tools", Visibility: "PRIVATE",
+				DependabotSecrets: []fakegithub.Secret{{Name: "GEM_TOKEN", CreatedAt: date(5, 1), UpdatedAt: date(5, 1)}},
+			},
+			{ID: 105, Name: "infra", Visibility: "INTERNAL"},
+		},
+		ActionsSecrets: []fakegithub.Secret{
+			{Name: "ORG_ALL", Visibility: "all", CreatedAt: date(1, 10), UpdatedAt: date(1, 10)},
+			{Name: "ORG_PRIVATE", Visibility: "private", CreatedAt: date(1, 11), UpdatedAt: date(1, 11)},
+			{Name: "ORG_SELECTED", Visibility: "selected", SelectedRepositories: []string{"tools", "api", "infra"}, CreatedAt: date(1, 12), UpdatedAt: date(1, 12)},
+			{Name: "ORG_UNSCOPED", Visibility: "selected", CreatedAt: date(1, 13), UpdatedAt: date(1, 13)},
+		},
+		DependabotSecrets: []fakegithub.Secret{
+			{Name: "ORG_NPM", Visibility: "private", CreatedAt: date(1, 14), UpdatedAt: date(1, 14)},
+		},
+		CodespacesSecrets: []fakegithub.Secret{
+			{Name: "ORG_CS", Visibility: "selected", SelectedRepositories: []string{"web"}, CreatedAt: date(1, 15), UpdatedAt: date(1, 15)},
+		},
+		Variables: []fakegithub.Variable{
+			{Name: "ORG_REGION", Value: "eu-west-1", Visibility: "all", CreatedAt: date(1, 16), UpdatedAt: date(1, 16)},
+		},
+	}
+}
+
+func TestRunCmd(t *testing.T) {
+	tests := []struct {
+		name            string
+		apps            []string
+		repos           []string
+		includeValues   bool
+		continueOnError bool
+		forbid          []string
+		wantErr         error
+	}{
+		{name: "all-apps", apps: []string{"all"}, includeValues: true},
+		{name: "actions", apps: []string{"actions"}},
+		{name: "named-repos", apps: []string{"actions", "environments"}, repos: []string{"web", "api"}},
+		{
+			name:    "forbidden",
+			apps:    []string{"all"},
+			forbid:  []string{"/repos/acme/api/dependabot/secrets"},
+			wantErr: data.ErrForbidden,
+		},
+		{
+			name:            "forbidden-continue",
+			apps:            []string{"all"},
+			continueOnError: true,
+			forbid:          []string{"/repos/acme/api/dependabot/secrets", "/orgs/acme/actions/secrets/ORG_SELECTED/repositories"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakegithub.NewServer(testOrganization())
+			defer server.Close()
+			server.MaxPerPage = 2
+			for _, path := range tt.forbid {
+				server.Forbid(path)
+			}
+			g, err := server.NewAPIGetter()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			appSet, err := inventory.ParseAppSet(tt.apps...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			flags := &cmdFlags{
+				format:          "csv",
+				concurrency:     2,
+				includeValues:   tt.includeValues,
+				continueOnError: tt.continueOnError,
+				appSet:          appSet,
+			}
+			failures := newFailureLog(tt.continueOnError)
+
+			var report bytes.Buffer
+			err = runCmd(context.Background(), "acme", tt.repos, flags, g, failures, newFindingLog(), &report)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("runCmd() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runCmd() error = %v", err)
+			}
+			checkGolden(t, tt.name+".golden", report.Bytes())
+
+			if tt.continueOnError {
+				var errorReport bytes.Buffer
+				if err = failures.report(stdoutOutput{&errorReport}, "errors.csv"); !errors.Is(err, ErrPartialReport) {
+					t.Errorf("failures.report() error = %v, want %v", err, ErrPartialReport)
+				}
+				checkGolden(t, tt.name+"-errors.golden", errorReport.Bytes())
+			}
+		})
+	}
+}
+
+// checkGolden compares got with testdata/name, rewriting it when -update
+// is set.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch:\n got:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestRunCmdSecretFlows(t *testing.T) {
+	org := testOrganization()
+	for i, repo := range org.Repositories {
+		switch repo.Name {
+		case "api":
+			org.Repositories[i].Files = map[string]string{
+				".github/workflows/deploy.yml": "on: push\njobs:\n  deploy:\n    uses: acme/infra/.github/workflows/apply.yml@main\n    secrets: inherit\n  scan:\n    uses: other-org/tools/.github/workflows/scan.yml@v1\n    secrets:\n      SCAN_TOKEN: ${{ secrets.SENTRY_DSN }}\n",
+			}
+		case "infra":
+			org.Repositories[i].Files = map[string]string{
+				".github/workflows/apply.yml": "on: workflow_call\njobs:\n  apply:\n    uses: ./.github/workflows/plan.yml\n    secrets:\n      KEY: ${{ secrets.DEPLOY_KEY }}\n",
+				".github/workflows/plan.yml":  "on: workflow_call\njobs:\n  plan:\n    runs-on: ubuntu-latest\n    steps:\n      - run: plan\n",
+			}
+		}
+	}
+	server := fakegithub.NewServer(org)
+	defer server.Close()
+	g, err := server.NewAPIGetter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appSet, err := inventory.ParseAppSet("actions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := &cmdFlags{format: "csv", concurrency: 2, scanUsage: true, appSet: appSet}
+	findings := newFindingLog()
+
+	var report bytes.Buffer
+	if err = runCmd(context.Background(), "acme", []string{"api", "infra"}, flags, g, newFailureLog(false), findings, &report); err != nil {
+		t.Fatalf("runCmd() error = %v", err)
+	}
+	checkGolden(t, "secret-flows.golden", report.Bytes())
+
+	var flowsReport bytes.Buffer
+	if _, err = findings.reportFlows(stdoutOutput{&flowsReport}); err != nil {
+		t.Fatalf("reportFlows() error = %v", err)
+	}
+	checkGolden(t, "secret-flows-flows.golden", flowsReport.Bytes())
+}
@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+	"github.com/katiem0/gh-export-secrets/internal/report"
+	"github.com/katiem0/gh-export-secrets/pkg/inventory"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+const (
+	staleFresh   = "fresh"
+	staleAging   = "aging"
+	staleOverdue = "overdue"
+)
+
+type staleFlags struct {
+	reportFile  string
+	summaryFile string
+	agingDays   int
+	overdueDays int
+	thresholds  []string
+}
+
+// staleThreshold is the age in days at which a secret becomes aging or overdue.
+type staleThreshold struct {
+	aging   int
+	overdue int
+}
+
+// stalePolicy resolves the threshold for a secret, preferring a
+// level/type override, then a type override, then a level override.
+type stalePolicy struct {
+	defaults  staleThreshold
+	overrides map[string]staleThreshold
+}
+
+// staleEntry is a single secret, with org level secrets collapsed to one
+// entry regardless of how many repositories can read them.
+type staleEntry struct {
+	export       data.SecretExport
+	repositories []string
+	ageDays      int
+	status       string
+}
+
+func newStaleCmd(cmdFlags *cmdFlags) *cobra.Command {
+	staleFlags := staleFlags{}
+
+	cmd := cobra.Command{
+		Use:   "stale [flags] <organization> [repo ...]",
+		Short: "Generate a rotation report ranking secrets by how long ago they were last updated.",
+		Long: "Generate a rotation report ranking secrets by how long ago they were last updated.\n\n" +
+			"Every app is ranked unless --app is set. Each secret is classified as fresh, aging or overdue.\n" +
+			"Thresholds default to --aging-days and --overdue-days and can be overridden per level and/or\n" +
+			"type with --threshold, for example:\n\n" +
+			"  --threshold Dependabot=30:60 --threshold Organization/Actions=90:120 --threshold Environment=60:90",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Arguments are valid, so further errors should not print usage
+			cmd.SilenceUsage = true
+
+			policy, err := newStalePolicy(&staleFlags)
+			if err != nil {
+				return err
+			}
+
+			// A rotation report covers every secret unless apps are picked
+			if !cmd.Flags().Changed("app") {
+				cmdFlags.appSet = inventory.NewAppSet(inventory.AllApps()...)
+			}
+
+			g, err := newAPIGetter(cmdFlags)
+			if err != nil {
+				return err
+			}
+
+			owner := args[0]
+			repos := args[1:]
+
+			reportWriter, err := openReportOutput(staleFlags.reportFile, cmdFlags.force)
+			if err != nil {
+				return err
+			}
+			defer reportWriter.Close() // nolint:errcheck
+
+			summaryWriter, err := openReportOutput(staleFlags.summaryFile, cmdFlags.force)
+			if err != nil {
+				return err
+			}
+			defer summaryWriter.Close() // nolint:errcheck
+
+			errorFileDefault := fmt.Sprintf("stale-errors-%s.csv", time.Now().Format("20060102150405"))
+			errorWriter, err := openErrorOutput(cmdFlags, staleFlags.reportFile, errorFileDefault)
+			if err != nil {
+				return err
+			}
+			if errorWriter != nil {
+				defer errorWriter.Close() // nolint:errcheck
+			}
+
+			failures := newFailureLog(cmdFlags.continueOnError)
+			err = runStaleCmd(cmd.Context(), owner, repos, cmdFlags, policy, g, failures, reportWriter, summaryWriter)
+			if err != nil {
+				return err
+			}
+			if err = reportWriter.Commit(); err != nil {
+				return err
+			}
+			if err = summaryWriter.Commit(); err != nil {
+				return err
+			}
+
+			return failures.report(errorWriter, cmdFlags.errorFile)
+		},
+	}
+
+	timestamp := time.Now().Format("20060102150405")
+	reportFileDefault := fmt.Sprintf("stale-report-%s.csv", timestamp)
+	summaryFileDefault := fmt.Sprintf("stale-summary-%s.csv", timestamp)
+
+	cmd.Flags().StringVarP(&staleFlags.reportFile, "output-file", "o", reportFileDefault, "Name of file to write the ranked CSV report, or - for stdout")
+	cmd.Flags().StringVarP(&staleFlags.summaryFile, "summary-file", "s", summaryFileDefault, "Name of file to write per organization and per repository counts, or - for stdout")
+	cmd.Flags().IntVarP(&staleFlags.agingDays, "aging-days", "", 90, "Days since last update after which a secret is aging")
+	cmd.Flags().IntVarP(&staleFlags.overdueDays, "overdue-days", "", 180, "Days since last update after which a secret is overdue")
+	cmd.Flags().StringArrayVarP(&staleFlags.thresholds, "threshold", "", nil, "Override thresholds as [<level>/]<type>=<aging>:<overdue> or <level>=<aging>:<overdue>")
+
+	return &cmd
+}
+
+func newStalePolicy(staleFlags *staleFlags) (*stalePolicy, error) {
+	policy := &stalePolicy{
+		defaults: staleThreshold{
+			aging:   staleFlags.agingDays,
+			overdue: staleFlags.overdueDays,
+		},
+		overrides: map[string]staleThreshold{},
+	}
+	if policy.defaults.aging > policy.defaults.overdue {
+		return nil, fmt.Errorf("--aging-days (%d) must not be greater than --overdue-days (%d)", policy.defaults.aging, policy.defaults.overdue)
+	}
+
+	for _, threshold := range staleFlags.thresholds {
+		key, value, found := strings.Cut(threshold, "=")
+		agingValue, overdueValue, foundDays := strings.Cut(value, ":")
+		if !found || !foundDays || key == "" {
+			return nil, fmt.Errorf("invalid --threshold %q: expected [<level>/]<type>=<aging>:<overdue>", threshold)
+		}
+		aging, err := strconv.Atoi(agingValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --threshold %q: %w", threshold, err)
+		}
+		overdue, err := strconv.Atoi(overdueValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --threshold %q: %w", threshold, err)
+		}
+		if aging > overdue {
+			return nil, fmt.Errorf("invalid --threshold %q: aging days must not be greater than overdue days", threshold)
+		}
+		if err = validateThresholdKey(key); err != nil {
+			return nil, fmt.Errorf("invalid --threshold %q: %w", threshold, err)
+		}
+		policy.overrides[strings.ToLower(key)] = staleThreshold{aging: aging, overdue: overdue}
+	}
+
+	return policy, nil
+}
+
+// validateThresholdKey checks that key is a known level, type, or level and
+// type, so that a typo is not silently ignored.
+func validateThresholdKey(key string) error {
+	levels := []string{"Organization", "Repository", "Environment"}
+	var types []string
+	for _, source := range inventory.Sources() {
+		if !slices.Contains(types, source.SecretType()) {
+			types = append(types, source.SecretType())
+		}
+	}
+	isOneOf := func(name string, known []string) bool {
+		return slices.ContainsFunc(known, func(k string) bool { return strings.EqualFold(k, name) })
+	}
+
+	level, secretType, found := strings.Cut(key, "/")
+	switch {
+	case found && !isOneOf(level, levels):
+		return fmt.Errorf("unknown level %q, expected one of: %s", level, strings.Join(levels, ", "))
+	case found && !isOneOf(secretType, types):
+		return fmt.Errorf("unknown type %q, expected one of: %s", secretType, strings.Join(types, ", "))
+	case !found && !isOneOf(key, levels) && !isOneOf(key, types):
+		return fmt.Errorf("unknown level or type %q, expected one of: %s", key, strings.Join(append(levels, types...), ", "))
+	}
+	return nil
+}
+
+// thresholdFor returns the most specific threshold configured for a secret.
+func (p *stalePolicy) thresholdFor(level string, secretType string) staleThreshold {
+	level = strings.ToLower(level)
+	secretType = strings.ToLower(secretType)
+
+	for _, key := range []string{level + "/" + secretType, secretType, level} {
+		if threshold, ok := p.overrides[key]; ok {
+			return threshold
+		}
+	}
+	return p.defaults
+}
+
+// classify returns the age in whole days and the fresh/aging/overdue status of a secret.
+func (p *stalePolicy) classify(export data.SecretExport, now time.Time) (int, string) {
+	lastUpdated := export.SecretUpdatedAt
+	if lastUpdated.IsZero() {
+		lastUpdated = export.SecretCreatedAt
+	}
+	ageDays := int(now.Sub(lastUpdated).Hours() / 24)
+
+	threshold := p.thresholdFor(export.SecretLevel, export.SecretType)
+	switch {
+	case ageDays >= threshold.overdue:
+		return ageDays, staleOverdue
+	case ageDays >= threshold.aging:
+		return ageDays, staleAging
+	default:
+		return ageDays, staleFresh
+	}
+}
+
+func runStaleCmd(ctx context.Context, owner string, repos []string, cmdFlags *cmdFlags, policy *stalePolicy, g data.Getter, failures *failureLog, reportWriter io.Writer, summaryWriter io.Writer) error {
+	var entries []*staleEntry
+	entryIndex := map[string]*staleEntry{}
+
+	err := collectSecrets(ctx, owner, repos, cmdFlags, g, failures, nil, func(export data.SecretExport) error {
+		key := strings.Join([]string{export.SecretLevel, export.SecretType, export.SecretName}, "/")
+		if export.SecretLevel != "Organization" {
+			key = strings.Join([]string{key, export.SecretAccess, export.RepositoryName}, "/")
+		}
+
+		entry, ok := entryIndex[key]
+		if !ok {
+			entry = &staleEntry{export: export}
+			entryIndex[key] = entry
+			entries = append(entries, entry)
+		}
+		if export.RepositoryName != "" {
+			entry.repositories = append(entry.repositories, export.RepositoryName)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		entry.ageDays, entry.status = policy.classify(entry.export, now)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].ageDays != entries[j].ageDays {
+			return entries[i].ageDays > entries[j].ageDays
+		}
+		return entries[i].export.SecretName < entries[j].export.SecretName
+	})
+
+	zap.S().Debugf("Classified %d secrets for %s", len(entries), owner)
+
+	if err = writeStaleReport(entries, reportWriter); err != nil {
+		return err
+	}
+	return writeStaleSummary(owner, entries, summaryWriter)
+}
+
+func writeStaleReport(entries []*staleEntry, reportWriter io.Writer) error {
+	csvWriter := csv.NewWriter(reportWriter)
+
+	err := csvWriter.Write([]string{
+		"Rank",
+		"Status",
+		"AgeDays",
+		"SecretLevel",
+		"SecretType",
+		"SecretName",
+		"SecretAccess",
+		"RepositoryName",
+		"RepositoryCount",
+		"SecretUpdatedAt",
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		repositoryName := ""
+		if entry.export.SecretLevel != "Organization" {
+			repositoryName = entry.export.RepositoryName
+		}
+		err = csvWriter.Write([]string{
+			strconv.Itoa(i + 1),
+			entry.status,
+			strconv.Itoa(entry.ageDays),
+			entry.export.SecretLevel,
+			entry.export.SecretType,
+			entry.export.SecretName,
+			entry.export.SecretAccess,
+			repositoryName,
+			strconv.Itoa(len(entry.repositories)),
+			report.FormatTime(entry.export.SecretUpdatedAt),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func writeStaleSummary(owner string, entries []*staleEntry, summaryWriter io.Writer) error {
+	orgCounts := map[string]int{}
+	repoCounts := map[string]map[string]int{}
+
+	for _, entry := range entries {
+		orgCounts[entry.status]++
+		for _, repo := range entry.repositories {
+			if repoCounts[repo] == nil {
+				repoCounts[repo] = map[string]int{}
+			}
+			repoCounts[repo][entry.status]++
+		}
+	}
+
+	csvWriter := csv.NewWriter(summaryWriter)
+
+	err := csvWriter.Write([]string{
+		"Scope",
+		"Name",
+		"Fresh",
+		"Aging",
+		"Overdue",
+		"Total",
+	})
+	if err != nil {
+		return err
+	}
+
+	err = csvWriter.Write(staleCountsRecord("Organization", owner, orgCounts))
+	if err != nil {
+		return err
+	}
+
+	repoNames := make([]string, 0, len(repoCounts))
+	for repo := range repoCounts {
+		repoNames = append(repoNames, repo)
+	}
+	sort.Strings(repoNames)
+
+	for _, repo := range repoNames {
+		err = csvWriter.Write(staleCountsRecord("Repository", repo, repoCounts[repo]))
+		if err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func staleCountsRecord(scope string, name string, counts map[string]int) []string {
+	return []string{
+		scope,
+		name,
+		strconv.Itoa(counts[staleFresh]),
+		strconv.Itoa(counts[staleAging]),
+		strconv.Itoa(counts[staleOverdue]),
+		strconv.Itoa(counts[staleFresh] + counts[staleAging] + counts[staleOverdue]),
+	}
+}
@@ -1,49 +1,15 @@
 package data
 
-import (
-	"fmt"
-	"io"
-	"log"
-)
+import "context"
 
-func (g *APIGetter) GetOrgActionSecrets(owner string) ([]byte, error) {
-	url := fmt.Sprintf("orgs/%s/actions/secrets", owner)
-
-	resp, err := g.restClient.Request("GET", url, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	responseData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return responseData, err
+func (g *APIGetter) GetOrgActionSecrets(ctx context.Context, owner string) ([]Secret, error) {
+	return g.getOrgSecrets(ctx, "actions", owner)
 }
 
-func (g *APIGetter) GetRepoActionSecrets(owner string, repo string) ([]byte, error) {
-	url := fmt.Sprintf("repos/%s/%s/actions/secrets", owner, repo)
-
-	resp, err := g.restClient.Request("GET", url, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	responseData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return responseData, err
+func (g *APIGetter) GetRepoActionSecrets(ctx context.Context, owner string, repo string) ([]Secret, error) {
+	return g.getRepoSecrets(ctx, "actions", owner, repo)
 }
 
-func (g *APIGetter) GetScopedOrgActionSecrets(owner string, secret string) ([]byte, error) {
-	url := fmt.Sprintf("orgs/%s/actions/secrets/%s/repositories", owner, secret)
-
-	resp, err := g.restClient.Request("GET", url, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	responseData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return responseData, err
+func (g *APIGetter) GetScopedOrgActionSecrets(ctx context.Context, owner string, secret string) ([]ScopedRepository, error) {
+	return g.getScopedOrgSecrets(ctx, "actions", owner, secret)
 }
@@ -1,49 +1,15 @@
 package data
 
-import (
-	"fmt"
-	"io"
-	"log"
-)
+import "context"
 
-func (g *APIGetter) GetOrgCodespacesSecrets(owner string) ([]byte, error) {
-	url := fmt.Sprintf("orgs/%s/codespaces/secrets", owner)
-
-	resp, err := g.restClient.Request("GET", url, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	responseData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return responseData, err
+func (g *APIGetter) GetOrgCodespacesSecrets(ctx context.Context, owner string) ([]Secret, error) {
+	return g.getOrgSecrets(ctx, "codespaces", owner)
 }
 
-func (g *APIGetter) GetRepoCodespacesSecrets(owner string, repo string) ([]byte, error) {
-	url := fmt.Sprintf("repos/%s/%s/codespaces/secrets", owner, repo)
-
-	resp, err := g.restClient.Request("GET", url, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	responseData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return responseData, err
+func (g *APIGetter) GetRepoCodespacesSecrets(ctx context.Context, owner string, repo string) ([]Secret, error) {
+	return g.getRepoSecrets(ctx, "codespaces", owner, repo)
 }
 
-func (g *APIGetter) GetScopedOrgCodespacesSecrets(owner string, secret string) ([]byte, error) {
-	url := fmt.Sprintf("orgs/%s/codespaces/secrets/%s/repositories", owner, secret)
-
-	resp, err := g.restClient.Request("GET", url, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	responseData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return responseData, err
+func (g *APIGetter) GetScopedOrgCodespacesSecrets(ctx context.Context, owner string, secret string) ([]ScopedRepository, error) {
+	return g.getScopedOrgSecrets(ctx, "codespaces", owner, secret)
 }
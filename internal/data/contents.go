@@ -0,0 +1,51 @@
+package data
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// GetDirectoryContents lists the files and directories at path on the
+// default branch of a repository.
+func (g *APIGetter) GetDirectoryContents(ctx context.Context, owner string, repo string, path string) ([]ContentEntry, error) {
+	url := fmt.Sprintf("repos/%s/%s/contents/%s", owner, repo, escapeContentPath(path))
+
+	var entries []ContentEntry
+	if err := g.restClient.DoWithContext(ctx, "GET", url, nil, &entries); err != nil {
+		return nil, newAPIError(url, err)
+	}
+	return entries, nil
+}
+
+// GetFileContents returns the decoded content of the file at path on the
+// default branch of a repository.
+func (g *APIGetter) GetFileContents(ctx context.Context, owner string, repo string, path string) ([]byte, error) {
+	url := fmt.Sprintf("repos/%s/%s/contents/%s", owner, repo, escapeContentPath(path))
+
+	var file FileContent
+	if err := g.restClient.DoWithContext(ctx, "GET", url, nil, &file); err != nil {
+		return nil, newAPIError(url, err)
+	}
+	if file.Encoding != "base64" {
+		// Files over 1 MB are returned without content
+		return nil, fmt.Errorf("%s: unsupported content encoding %q", url, file.Encoding)
+	}
+
+	content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return content, nil
+}
+
+// escapeContentPath escapes each segment of a repository file path.
+func escapeContentPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
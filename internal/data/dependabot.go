@@ -1,49 +1,38 @@
 package data
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 )
 
-func (g *APIGetter) GetOrgDependabotSecrets(owner string) ([]byte, error) {
-	url := fmt.Sprintf("orgs/%s/dependabot/secrets", owner)
+func (g *APIGetter) GetOrgDependabotSecrets(ctx context.Context, owner string) ([]Secret, error) {
+	return g.getOrgSecrets(ctx, "dependabot", owner)
+}
 
-	resp, err := g.restClient.Request("GET", url, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	responseData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return responseData, err
+func (g *APIGetter) GetRepoDependabotSecrets(ctx context.Context, owner string, repo string) ([]Secret, error) {
+	return g.getRepoSecrets(ctx, "dependabot", owner, repo)
 }
 
-func (g *APIGetter) GetRepoDependabotSecrets(owner string, repo string) ([]byte, error) {
-	url := fmt.Sprintf("repos/%s/%s/dependabot/secrets", owner, repo)
+// GetDependabotSecurityUpdates reports whether Dependabot security updates
+// are enabled in the settings of a repository. A 404, which GitHub returns
+// when they are not enabled, is reported as disabled.
+func (g *APIGetter) GetDependabotSecurityUpdates(ctx context.Context, owner string, repo string) (bool, error) {
+	url := fmt.Sprintf("repos/%s/%s/automated-security-fixes", owner, repo)
 
-	resp, err := g.restClient.Request("GET", url, nil)
-	if err != nil {
-		log.Fatal(err)
+	var status struct {
+		Enabled bool `json:"enabled"`
 	}
-	responseData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatal(err)
+	if err := g.restClient.DoWithContext(ctx, "GET", url, nil, &status); err != nil {
+		err = newAPIError(url, err)
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
 	}
-	return responseData, err
+	return status.Enabled, nil
 }
 
-func (g *APIGetter) GetScopedOrgDependabotSecrets(owner string, secret string) ([]byte, error) {
-	url := fmt.Sprintf("orgs/%s/dependabot/secrets/%s/repositories", owner, secret)
-
-	resp, err := g.restClient.Request("GET", url, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	responseData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return responseData, err
+func (g *APIGetter) GetScopedOrgDependabotSecrets(ctx context.Context, owner string, secret string) ([]ScopedRepository, error) {
+	return g.getScopedOrgSecrets(ctx, "dependabot", owner, secret)
 }
@@ -0,0 +1,28 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+func (g *APIGetter) GetRepoEnvironments(ctx context.Context, owner string, repo string) ([]Environment, error) {
+	url := fmt.Sprintf("repos/%s/%s/environments", owner, repo)
+
+	environments, err := getAllPages[Environment](ctx, g, url, "environments")
+	if err != nil {
+		return nil, err
+	}
+	return environments, nil
+}
+
+func (g *APIGetter) GetEnvironmentSecrets(ctx context.Context, owner string, repo string, environment string) ([]Secret, error) {
+	envName := url.PathEscape(environment)
+	url := fmt.Sprintf("repos/%s/%s/environments/%s/secrets", owner, repo, envName)
+
+	secrets, err := getAllPages[Secret](ctx, g, url, "secrets")
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
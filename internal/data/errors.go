@@ -0,0 +1,100 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+)
+
+// Sentinel errors describing why a request failed. Use errors.Is against
+// an error returned by APIGetter to decide whether to skip, retry or abort.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrForbidden   = errors.New("forbidden")
+	ErrRateLimited = errors.New("rate limited")
+	ErrServer      = errors.New("server error")
+)
+
+// APIError is returned by APIGetter when a REST or GraphQL request fails.
+type APIError struct {
+	// Kind is one of the sentinel errors, or nil if the failure is not classified.
+	Kind       error
+	Endpoint   string
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s: HTTP %d: %s", e.Endpoint, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Endpoint, e.Message)
+}
+
+func (e *APIError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// newAPIError classifies an error returned by the go-gh REST or GraphQL clients.
+func newAPIError(endpoint string, err error) error {
+	apiErr := &APIError{
+		Endpoint: endpoint,
+		Message:  err.Error(),
+		Err:      err,
+	}
+
+	var httpErr *api.HTTPError
+	var gqlErr *api.GraphQLError
+
+	switch {
+	case errors.As(err, &httpErr):
+		apiErr.StatusCode = httpErr.StatusCode
+		if httpErr.Message != "" {
+			apiErr.Message = httpErr.Message
+		}
+		apiErr.Kind = classifyStatus(httpErr)
+	case errors.As(err, &gqlErr):
+		for _, item := range gqlErr.Errors {
+			switch item.Type {
+			case "NOT_FOUND":
+				apiErr.Kind = ErrNotFound
+			case "FORBIDDEN":
+				apiErr.Kind = ErrForbidden
+			case "RATE_LIMITED":
+				apiErr.Kind = ErrRateLimited
+			}
+		}
+	}
+
+	return apiErr
+}
+
+func classifyStatus(httpErr *api.HTTPError) error {
+	switch {
+	case httpErr.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case httpErr.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case httpErr.StatusCode == http.StatusForbidden && isRateLimitMessage(httpErr):
+		return ErrRateLimited
+	case httpErr.StatusCode == http.StatusForbidden || httpErr.StatusCode == http.StatusUnauthorized:
+		return ErrForbidden
+	case httpErr.StatusCode >= http.StatusInternalServerError:
+		return ErrServer
+	}
+	return nil
+}
+
+func isRateLimitMessage(httpErr *api.HTTPError) bool {
+	if httpErr.Headers.Get("X-RateLimit-Remaining") == "0" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(httpErr.Message), "rate limit")
+}
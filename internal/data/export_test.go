@@ -0,0 +1,18 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+const MaxServerRetries = maxServerRetries
+
+var Backoff = backoff
+
+// SetBackoffBase shortens the delay before retrying a gateway error until
+// the test finishes.
+func SetBackoffBase(t *testing.T, base time.Duration) {
+	previous := backoffBase
+	backoffBase = base
+	t.Cleanup(func() { backoffBase = previous })
+}
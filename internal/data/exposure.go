@@ -0,0 +1,87 @@
+package data
+
+import (
+	"sort"
+	"strings"
+)
+
+// ExposedRepository is a repository that is able to read an organization
+// level secret or variable, along with the visibility that granted it.
+type ExposedRepository struct {
+	ID         int
+	Name       string
+	Visibility string
+}
+
+// NormalizeVisibility lowercases a repository visibility so that GraphQL
+// values (PUBLIC, PRIVATE, INTERNAL) and REST values (public, private,
+// internal) compare equally.
+func NormalizeVisibility(visibility string) string {
+	return strings.ToLower(visibility)
+}
+
+// ResolveExposure maps an organization level secret or variable to the exact
+// set of repositories in allRepos that can read it.
+//
+//   - all: every repository, regardless of visibility
+//   - private: every private and internal repository
+//   - selected: only the repositories returned by the scoped repository
+//     endpoint, which must be passed in as scoped
+func ResolveExposure(visibility string, allRepos []RepoInfo, scoped []ScopedRepository) []ExposedRepository {
+	var exposed []ExposedRepository
+
+	switch strings.ToLower(visibility) {
+	case "all":
+		for _, repo := range allRepos {
+			exposed = append(exposed, ExposedRepository{
+				ID:         repo.DatabaseId,
+				Name:       repo.Name,
+				Visibility: NormalizeVisibility(repo.Visibility),
+			})
+		}
+	case "private":
+		for _, repo := range allRepos {
+			repoVisibility := NormalizeVisibility(repo.Visibility)
+			if repoVisibility == "private" || repoVisibility == "internal" {
+				exposed = append(exposed, ExposedRepository{
+					ID:         repo.DatabaseId,
+					Name:       repo.Name,
+					Visibility: repoVisibility,
+				})
+			}
+		}
+	case "selected":
+		known := make(map[int]RepoInfo, len(allRepos))
+		for _, repo := range allRepos {
+			known[repo.DatabaseId] = repo
+		}
+		for _, repo := range scoped {
+			exposed = append(exposed, ExposedRepository{
+				ID:         repo.ID,
+				Name:       repo.Name,
+				Visibility: scopedVisibility(repo, known),
+			})
+		}
+		sort.SliceStable(exposed, func(i, j int) bool {
+			return strings.ToLower(exposed[i].Name) < strings.ToLower(exposed[j].Name)
+		})
+	}
+
+	return exposed
+}
+
+// scopedVisibility prefers the GraphQL visibility of a repository, since the
+// scoped repository endpoint does not return visibility on every version of
+// GitHub Enterprise Server.
+func scopedVisibility(repo ScopedRepository, known map[int]RepoInfo) string {
+	if info, ok := known[repo.ID]; ok && info.Visibility != "" {
+		return NormalizeVisibility(info.Visibility)
+	}
+	if repo.Visibility != "" {
+		return NormalizeVisibility(repo.Visibility)
+	}
+	if repo.Private {
+		return "private"
+	}
+	return "public"
+}
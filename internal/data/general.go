@@ -1,26 +1,61 @@
 package data
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/cli/go-gh/v2/pkg/api"
 	"github.com/shurcooL/graphql"
 )
 
+// Getter retrieves repositories, secrets and variables for an organization.
+// Every list is fully paginated and decoded. Requests are made with ctx,
+// so cancelling it interrupts a request in flight or a wait for a rate
+// limit to reset. APIGetter implements it against the GitHub API.
 type Getter interface {
-	GetReposList(owner string, endCursor *string) ([]ReposQuery, error)
-	GetRepo(owner string, name string) ([]RepoQuery, error)
-	GetOrgActionSecrets(owner string) ([]byte, error)
-	GetRepoActionSecrets(owner string, repo string) ([]byte, error)
-	GetScopedOrgActionSecrets(owner string, secret string) ([]byte, error)
-	GetOrgDependabotSecrets(owner string) ([]byte, error)
-	GetRepoDependabotSecrets(owner string, repo string) ([]byte, error)
-	GetScopedOrgDependabotSecrets(owner string, secret string) ([]byte, error)
-	GetOrgCodespacesSecrets(owner string) ([]byte, error)
-	GetRepoCodespacesSecrets(owner string, repo string) ([]byte, error)
-	GetScopedOrgCodespacesSecrets(owner string, secret string) ([]byte, error)
+	GetReposList(ctx context.Context, owner string, endCursor *string) (*ReposQuery, error)
+	GetRepo(ctx context.Context, owner string, name string) (*RepoQuery, error)
+	GetOrgActionSecrets(ctx context.Context, owner string) ([]Secret, error)
+	GetRepoActionSecrets(ctx context.Context, owner string, repo string) ([]Secret, error)
+	GetScopedOrgActionSecrets(ctx context.Context, owner string, secret string) ([]ScopedRepository, error)
+	GetOrgDependabotSecrets(ctx context.Context, owner string) ([]Secret, error)
+	GetRepoDependabotSecrets(ctx context.Context, owner string, repo string) ([]Secret, error)
+	GetScopedOrgDependabotSecrets(ctx context.Context, owner string, secret string) ([]ScopedRepository, error)
+	GetOrgCodespacesSecrets(ctx context.Context, owner string) ([]Secret, error)
+	GetRepoCodespacesSecrets(ctx context.Context, owner string, repo string) ([]Secret, error)
+	GetScopedOrgCodespacesSecrets(ctx context.Context, owner string, secret string) ([]ScopedRepository, error)
+	GetRepoEnvironments(ctx context.Context, owner string, repo string) ([]Environment, error)
+	GetEnvironmentSecrets(ctx context.Context, owner string, repo string, environment string) ([]Secret, error)
+	GetOrgActionVariables(ctx context.Context, owner string) ([]Variable, error)
+	GetRepoActionVariables(ctx context.Context, owner string, repo string) ([]Variable, error)
+	GetScopedOrgActionVariables(ctx context.Context, owner string, variable string) ([]ScopedRepository, error)
+	GetEnvironmentVariables(ctx context.Context, owner string, repo string, environment string) ([]Variable, error)
 }
 
+// ContentGetter reads files from the default branch of a repository. It is
+// only needed to scan repositories for the secrets they reference, so a
+// Getter may leave it out. APIGetter implements it.
+type ContentGetter interface {
+	GetDirectoryContents(ctx context.Context, owner string, repo string, path string) ([]ContentEntry, error)
+	GetFileContents(ctx context.Context, owner string, repo string, path string) ([]byte, error)
+}
+
+// DependabotGetter reports whether Dependabot security updates are enabled
+// in the settings of a repository, which runs Dependabot without a
+// configuration file. A Getter may leave it out, in which case only
+// repositories with a configuration are taken to run Dependabot. APIGetter
+// implements it.
+type DependabotGetter interface {
+	GetDependabotSecurityUpdates(ctx context.Context, owner string, repo string) (bool, error)
+}
+
+var (
+	_ Getter           = (*APIGetter)(nil)
+	_ ContentGetter    = (*APIGetter)(nil)
+	_ DependabotGetter = (*APIGetter)(nil)
+)
+
 type APIGetter struct {
 	gqlClient  api.GraphQLClient
 	restClient api.RESTClient
@@ -34,12 +69,23 @@ func NewAPIGetter(gqlClient *api.GraphQLClient, restClient *api.RESTClient) *API
 }
 
 type SecretExport struct {
-	SecretLevel    string
-	SecretType     string
-	SecretName     string
-	SecretAccess   string
-	RepositoryName string
-	RepositoryID   int
+	SecretLevel          string    `json:"secret_level"`
+	SecretType           string    `json:"secret_type"`
+	SecretName           string    `json:"secret_name"`
+	SecretAccess         string    `json:"secret_access"`
+	RepositoryName       string    `json:"repository_name,omitempty"`
+	RepositoryID         int       `json:"repository_id,omitempty"`
+	RepositoryVisibility string    `json:"repository_visibility,omitempty"`
+	SecretCreatedAt      time.Time `json:"secret_created_at"`
+	SecretUpdatedAt      time.Time `json:"secret_updated_at"`
+	VariableValue        string    `json:"variable_value,omitempty"`
+	// Referenced is set when usage was scanned, and reports whether a file
+	// in the repository references the secret. ReferencedBy lists those files.
+	Referenced   *bool    `json:"referenced,omitempty"`
+	ReferencedBy []string `json:"referenced_by,omitempty"`
+	// IndirectConsumers lists the other repositories whose reusable
+	// workflows are passed the secret by the repository's workflows.
+	IndirectConsumers []string `json:"indirect_consumers,omitempty"`
 }
 
 type RepoInfo struct {
@@ -66,27 +112,33 @@ type RepoQuery struct {
 	Repository RepoInfo `graphql:"repository(owner: $owner, name: $name)"`
 }
 
-func (g *APIGetter) GetReposList(owner string, endCursor *string) (*ReposQuery, error) {
+func (g *APIGetter) GetReposList(ctx context.Context, owner string, endCursor *string) (*ReposQuery, error) {
 	query := new(ReposQuery)
 	variables := map[string]interface{}{
 		"endCursor": (*graphql.String)(endCursor),
 		"owner":     graphql.String(owner),
 	}
 
-	err := g.gqlClient.Query("getRepos", &query, variables)
+	err := g.gqlClient.QueryWithContext(ctx, "getRepos", &query, variables)
+	if err != nil {
+		return nil, newAPIError(fmt.Sprintf("graphql getRepos %s", owner), err)
+	}
 
-	return query, err
+	return query, nil
 }
 
-func (g *APIGetter) GetRepo(owner string, name string) (*RepoQuery, error) {
+func (g *APIGetter) GetRepo(ctx context.Context, owner string, name string) (*RepoQuery, error) {
 	query := new(RepoQuery)
 	variables := map[string]interface{}{
 		"owner": graphql.String(owner),
 		"name":  graphql.String(name),
 	}
 
-	err := g.gqlClient.Query("getRepo", &query, variables)
-	return query, err
+	err := g.gqlClient.QueryWithContext(ctx, "getRepo", &query, variables)
+	if err != nil {
+		return nil, newAPIError(fmt.Sprintf("graphql getRepo %s/%s", owner, name), err)
+	}
+	return query, nil
 }
 
 type SecretsResponse struct {
@@ -102,12 +154,87 @@ type Secret struct {
 	SelectedRepos string    `json:"selected_repositories_url"`
 }
 
+type VariablesResponse struct {
+	TotalCount int        `json:"total_count"`
+	Variables  []Variable `json:"variables"`
+}
+
+type Variable struct {
+	Name          string    `json:"name"`
+	Value         string    `json:"value"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Visibility    string    `json:"visibility"`
+	SelectedRepos string    `json:"selected_repositories_url"`
+}
+
 type ScopedSecretsResponse struct {
 	TotalCount   int                `json:"total_count"`
 	Repositories []ScopedRepository `json:"repositories"`
 }
 
 type ScopedRepository struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Private    bool   `json:"private"`
+	Visibility string `json:"visibility"`
+}
+
+type EnvironmentsResponse struct {
+	TotalCount   int           `json:"total_count"`
+	Environments []Environment `json:"environments"`
+}
+
+type Environment struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
 }
+
+type ContentEntry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+type FileContent struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Type     string `json:"type"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+}
+
+// Finding kinds reported when usage is scanned.
+const (
+	FindingMissingSecret = "MissingSecret"
+	FindingUnusedSecret  = "UnusedSecret"
+	FindingDependabotRun = "DependabotRunSecret"
+)
+
+// Finding is a problem found by comparing the secrets a repository
+// references with the secrets it can read.
+type Finding struct {
+	Kind           string `json:"kind"`
+	RepositoryName string `json:"repository_name"`
+	Path           string `json:"path"`
+	Line           int    `json:"line,omitempty"`
+	SecretType     string `json:"secret_type"`
+	SecretName     string `json:"secret_name"`
+	Message        string `json:"message"`
+}
+
+// SecretFlow is a secret passed by a workflow to a reusable workflow it
+// calls. RepositoryName and SecretName identify the secret where it is
+// read, which for nested calls is not the caller of this hop.
+type SecretFlow struct {
+	RepositoryName   string `json:"repository_name"`
+	SecretName       string `json:"secret_name"`
+	CallerRepository string `json:"caller_repository"`
+	CallerPath       string `json:"caller_path"`
+	Line             int    `json:"line,omitempty"`
+	CalleeRepository string `json:"callee_repository"`
+	CalleeWorkflow   string `json:"callee_workflow"`
+	CalleeRef        string `json:"callee_ref,omitempty"`
+	PassedAs         string `json:"passed_as"`
+	Inherited        bool   `json:"inherited"`
+}
@@ -0,0 +1,91 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// perPage is the largest page size accepted by the secrets, variables and
+// scoped repository endpoints. Endpoints with a lower limit clamp it.
+const perPage = 100
+
+var linkNextRE = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// getAllPages requests every page of a REST list endpoint and decodes the
+// array found under key on each page, e.g. "secrets" in
+// {"total_count": n, "secrets": [...]}.
+//
+// The next page is taken from the Link header. When a server strips Link
+// headers, total_count is used to decide whether another page is needed.
+func getAllPages[T any](ctx context.Context, g *APIGetter, path string, key string) ([]T, error) {
+	items := []T{}
+	var totalCount int
+
+	page := 1
+	next := pageURL(path, page)
+
+	for next != "" {
+		resp, err := g.restClient.RequestWithContext(ctx, "GET", next, nil)
+		if err != nil {
+			return nil, newAPIError(path, err)
+		}
+		responseData, err := io.ReadAll(resp.Body)
+		resp.Body.Close() // nolint:errcheck
+		if err != nil {
+			return nil, err
+		}
+
+		var pageObject map[string]json.RawMessage
+		if err = json.Unmarshal(responseData, &pageObject); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if raw, ok := pageObject["total_count"]; ok {
+			if err = json.Unmarshal(raw, &totalCount); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		var pageItems []T
+		if raw, ok := pageObject[key]; ok {
+			if err = json.Unmarshal(raw, &pageItems); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		items = append(items, pageItems...)
+
+		page++
+		next = nextPageURL(resp.Header.Get("Link"))
+		if next == "" && len(pageItems) > 0 && len(items) < totalCount {
+			next = pageURL(path, page)
+		}
+	}
+
+	return items, nil
+}
+
+// pageURL appends per_page and page query parameters to a REST path.
+func pageURL(path string, page int) string {
+	params := url.Values{}
+	params.Set("per_page", strconv.Itoa(perPage))
+	params.Set("page", strconv.Itoa(page))
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%s%s", path, separator, params.Encode())
+}
+
+// nextPageURL returns the rel="next" target of a Link header, if any.
+func nextPageURL(link string) string {
+	match := linkNextRE.FindStringSubmatch(link)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
@@ -0,0 +1,142 @@
+package data_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+	"github.com/katiem0/gh-export-secrets/internal/fakegithub"
+)
+
+func secrets(n int) []fakegithub.Secret {
+	var secrets []fakegithub.Secret
+	for i := 0; i < n; i++ {
+		secrets = append(secrets, fakegithub.Secret{Name: fmt.Sprintf("SECRET_%d", i), Visibility: "private"})
+	}
+	return secrets
+}
+
+func TestGetAllPages(t *testing.T) {
+	tests := []struct {
+		name         string
+		secrets      int
+		stripLinks   bool
+		wantRequests []string
+	}{
+		{
+			name:    "follows Link",
+			secrets: 5,
+			wantRequests: []string{
+				"GET /orgs/acme/actions/secrets?page=1&per_page=100",
+				"GET /orgs/acme/actions/secrets?page=2&per_page=2",
+				"GET /orgs/acme/actions/secrets?page=3&per_page=2",
+			},
+		},
+		{
+			name:       "falls back to total_count",
+			secrets:    5,
+			stripLinks: true,
+			wantRequests: []string{
+				"GET /orgs/acme/actions/secrets?page=1&per_page=100",
+				"GET /orgs/acme/actions/secrets?page=2&per_page=100",
+				"GET /orgs/acme/actions/secrets?page=3&per_page=100",
+			},
+		},
+		{
+			name:       "stops on a full last page",
+			secrets:    4,
+			stripLinks: true,
+			wantRequests: []string{
+				"GET /orgs/acme/actions/secrets?page=1&per_page=100",
+				"GET /orgs/acme/actions/secrets?page=2&per_page=100",
+			},
+		},
+		{
+			name:         "single empty page",
+			stripLinks:   true,
+			wantRequests: []string{"GET /orgs/acme/actions/secrets?page=1&per_page=100"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakegithub.NewServer(&fakegithub.Organization{Login: "acme", ActionsSecrets: secrets(tt.secrets)})
+			defer server.Close()
+			server.MaxPerPage = 2
+			server.StripLinks = tt.stripLinks
+
+			g, err := server.NewAPIGetter()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := g.GetOrgActionSecrets(context.Background(), "acme")
+			if err != nil {
+				t.Fatalf("GetOrgActionSecrets() error = %v", err)
+			}
+			if len(got) != tt.secrets {
+				t.Fatalf("GetOrgActionSecrets() returned %d secrets, want %d", len(got), tt.secrets)
+			}
+			for i, secret := range got {
+				if want := fmt.Sprintf("SECRET_%d", i); secret.Name != want {
+					t.Errorf("GetOrgActionSecrets()[%d] = %s, want %s", i, secret.Name, want)
+				}
+			}
+			if requests := server.Requests(); !reflect.DeepEqual(requests, tt.wantRequests) {
+				t.Errorf("server received %q, want %q", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestScopedRepositoriesArePaginated(t *testing.T) {
+	selected := []string{"repo0", "repo1", "repo2", "repo3", "repo4"}
+	org := &fakegithub.Organization{Login: "acme"}
+	for i, name := range selected {
+		org.Repositories = append(org.Repositories, fakegithub.Repository{ID: i + 1, Name: name, Visibility: "PRIVATE"})
+	}
+	scoped := fakegithub.Secret{Name: "SCOPED", Visibility: "selected", SelectedRepositories: selected}
+	org.ActionsSecrets = []fakegithub.Secret{scoped}
+	org.DependabotSecrets = []fakegithub.Secret{scoped}
+	org.CodespacesSecrets = []fakegithub.Secret{scoped}
+	org.Variables = []fakegithub.Variable{{Name: "SCOPED", Visibility: "selected", SelectedRepositories: selected}}
+
+	getters := map[string]func(data.Getter, context.Context, string, string) ([]data.ScopedRepository, error){
+		"Actions":    data.Getter.GetScopedOrgActionSecrets,
+		"Dependabot": data.Getter.GetScopedOrgDependabotSecrets,
+		"Codespaces": data.Getter.GetScopedOrgCodespacesSecrets,
+		"Variables":  data.Getter.GetScopedOrgActionVariables,
+	}
+	for _, stripLinks := range []bool{false, true} {
+		for name, get := range getters {
+			t.Run(fmt.Sprintf("%s stripLinks=%t", name, stripLinks), func(t *testing.T) {
+				server := fakegithub.NewServer(org)
+				defer server.Close()
+				server.MaxPerPage = 2
+				server.StripLinks = stripLinks
+
+				g, err := server.NewAPIGetter()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				repos, err := get(g, context.Background(), "acme", "SCOPED")
+				if err != nil {
+					t.Fatalf("scoped repositories error = %v", err)
+				}
+				var names []string
+				for _, repo := range repos {
+					names = append(names, repo.Name)
+				}
+				if !reflect.DeepEqual(names, selected) {
+					t.Errorf("scoped repositories = %v, want %v", names, selected)
+				}
+				if got := len(server.Requests()); got != 3 {
+					t.Errorf("server received %d requests, want 3", got)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package data
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// capture is a recorded request and response, stored as one JSON file per
+// distinct request in a --record directory.
+type capture struct {
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	RequestHeaders  http.Header `json:"request_headers,omitempty"`
+	RequestBody     string      `json:"request_body,omitempty"`
+	StatusCode      int         `json:"status_code"`
+	ResponseHeaders http.Header `json:"response_headers,omitempty"`
+	ResponseBody    string      `json:"response_body"`
+}
+
+// RecordTransport is an http.RoundTripper that saves every response from
+// base to a directory so a run can later be repeated with ReplayTransport.
+// Authorization headers are never written to disk.
+type RecordTransport struct {
+	base http.RoundTripper
+	dir  string
+
+	mu sync.Mutex
+}
+
+// NewRecordTransport creates dir if needed and wraps base, or
+// http.DefaultTransport when base is nil.
+func NewRecordTransport(base http.RoundTripper, dir string) (*RecordTransport, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &RecordTransport{base: base, dir: dir}, nil
+}
+
+func (t *RecordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, requestBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close() // nolint:errcheck
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	requestHeaders := req.Header.Clone()
+	requestHeaders.Del("Authorization")
+	responseHeaders := resp.Header.Clone()
+	responseHeaders.Del("Set-Cookie")
+
+	entry := capture{
+		Method:          req.Method,
+		URL:             req.URL.RequestURI(),
+		RequestHeaders:  requestHeaders,
+		RequestBody:     string(requestBody),
+		StatusCode:      resp.StatusCode,
+		ResponseHeaders: responseHeaders,
+		ResponseBody:    string(responseBody),
+	}
+	if err := t.save(entry); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (t *RecordTransport) save(entry capture) error {
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entry); err != nil {
+		return err
+	}
+
+	path := filepath.Join(t.dir, captureName(entry.Method, entry.URL, []byte(entry.RequestBody)))
+	zap.S().Debugf("Recording %s %s to %s", entry.Method, entry.URL, path)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return os.WriteFile(path, content.Bytes(), 0o600)
+}
+
+// ReplayTransport is an http.RoundTripper that answers requests from the
+// captures written by RecordTransport without touching the network.
+type ReplayTransport struct {
+	dir string
+}
+
+// NewReplayTransport replays the captures in dir.
+func NewReplayTransport(dir string) (*ReplayTransport, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("replay path %s is not a directory", dir)
+	}
+	return &ReplayTransport{dir: dir}, nil
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, requestBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(t.dir, captureName(req.Method, req.URL.RequestURI(), requestBody))
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no recorded response for %s %s in %s", req.Method, req.URL.RequestURI(), t.dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry capture
+	if err = json.Unmarshal(content, &entry); err != nil {
+		return nil, fmt.Errorf("reading capture %s: %w", path, err)
+	}
+	zap.S().Debugf("Replaying %s %s from %s", req.Method, req.URL.RequestURI(), path)
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.StatusCode, http.StatusText(entry.StatusCode)),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.ResponseHeaders,
+		Body:          io.NopCloser(bytes.NewReader([]byte(entry.ResponseBody))),
+		ContentLength: int64(len(entry.ResponseBody)),
+		Request:       req,
+	}, nil
+}
+
+// readRequestBody returns the body of req along with a copy of req whose
+// body can be read again, leaving the caller's request untouched.
+func readRequestBody(req *http.Request) (*http.Request, []byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close() // nolint:errcheck
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clone := req.Clone(req.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	clone.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return clone, body, nil
+}
+
+// captureName identifies a request by method, path, query and body, but not
+// host, so captures can be replayed regardless of how the host is spelled.
+func captureName(method string, requestURI string, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", method, requestURI)
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))[:32] + ".json"
+}
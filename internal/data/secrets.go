@@ -0,0 +1,39 @@
+package data
+
+import (
+	"context"
+	"fmt"
+)
+
+// The Actions, Dependabot and Codespaces secrets APIs share one layout,
+// differing only in the app segment of the path, e.g. "actions".
+
+func (g *APIGetter) getOrgSecrets(ctx context.Context, app string, owner string) ([]Secret, error) {
+	url := fmt.Sprintf("orgs/%s/%s/secrets", owner, app)
+
+	secrets, err := getAllPages[Secret](ctx, g, url, "secrets")
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func (g *APIGetter) getRepoSecrets(ctx context.Context, app string, owner string, repo string) ([]Secret, error) {
+	url := fmt.Sprintf("repos/%s/%s/%s/secrets", owner, repo, app)
+
+	secrets, err := getAllPages[Secret](ctx, g, url, "secrets")
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func (g *APIGetter) getScopedOrgSecrets(ctx context.Context, app string, owner string, secret string) ([]ScopedRepository, error) {
+	url := fmt.Sprintf("orgs/%s/%s/secrets/%s/repositories", owner, app, secret)
+
+	repositories, err := getAllPages[ScopedRepository](ctx, g, url, "repositories")
+	if err != nil {
+		return nil, err
+	}
+	return repositories, nil
+}
@@ -0,0 +1,265 @@
+package data
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxServerRetries is the number of times an idempotent request is
+	// retried after a 502, 503 or 504 response or a transport error.
+	maxServerRetries = 5
+	// maxRateLimitRetries bounds how many times a single request waits for a
+	// rate limit to reset, so a misbehaving server cannot stall a run forever.
+	maxRateLimitRetries = 10
+	// secondaryRateLimitWait is used when a secondary rate limit response
+	// carries no Retry-After header, as recommended by the GitHub docs.
+	secondaryRateLimitWait = time.Minute
+	backoffMax             = 30 * time.Second
+)
+
+// backoffBase is the ceiling of the delay before the first retry after a
+// gateway error. Tests shorten it.
+var backoffBase = time.Second
+
+// RateLimitTransport is an http.RoundTripper for the REST and GraphQL
+// clients used by APIGetter. It waits out primary and secondary rate limits
+// rather than failing, and retries idempotent requests that fail with a
+// gateway error using jittered exponential backoff.
+type RateLimitTransport struct {
+	base http.RoundTripper
+
+	mu         sync.Mutex
+	blockUntil time.Time
+}
+
+// NewRateLimitTransport wraps base, or http.DefaultTransport when base is nil.
+func NewRateLimitTransport(base http.RoundTripper) *RateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RateLimitTransport{base: base}
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	serverRetries := 0
+	rateLimitRetries := 0
+
+	for {
+		if err := t.waitForReset(req); err != nil {
+			return nil, err
+		}
+
+		attempt, err := rewindRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(attempt)
+		if err != nil {
+			if !isIdempotent(req) || serverRetries >= maxServerRetries || req.Context().Err() != nil {
+				return nil, err
+			}
+			serverRetries++
+			wait := backoff(serverRetries)
+			zap.S().Debugf("Retrying %s %s in %s after error: %v", req.Method, req.URL, wait, err)
+			if err = sleepContext(req, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		t.recordRateLimit(resp)
+
+		if wait, limited := rateLimitWait(resp); limited {
+			if rateLimitRetries >= maxRateLimitRetries {
+				return resp, nil
+			}
+			rateLimitRetries++
+			drainBody(resp)
+			zap.S().Infof("Rate limit reached for %s, waiting %s before retrying", req.URL.Path, wait.Round(time.Second))
+			if err = sleepContext(req, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if isGatewayError(resp.StatusCode) && isIdempotent(req) && serverRetries < maxServerRetries {
+			serverRetries++
+			drainBody(resp)
+			wait := backoff(serverRetries)
+			zap.S().Debugf("Retrying %s %s in %s after HTTP %d", req.Method, req.URL, wait, resp.StatusCode)
+			if err = sleepContext(req, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return resp, nil
+	}
+}
+
+// waitForReset blocks while a previous response reported that the primary
+// rate limit is exhausted, so concurrent requests do not all fail at once.
+func (t *RateLimitTransport) waitForReset(req *http.Request) error {
+	t.mu.Lock()
+	wait := time.Until(t.blockUntil)
+	t.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	zap.S().Infof("Rate limit exhausted, waiting %s for it to reset", wait.Round(time.Second))
+	return sleepContext(req, wait)
+}
+
+// recordRateLimit remembers the reset time when X-RateLimit-Remaining hits zero.
+func (t *RateLimitTransport) recordRateLimit(resp *http.Response) {
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+	reset, ok := rateLimitReset(resp)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	if reset.After(t.blockUntil) {
+		t.blockUntil = reset
+	}
+	t.mu.Unlock()
+}
+
+// rateLimitWait reports whether resp is a primary or secondary rate limit
+// response and how long to wait before retrying.
+func rateLimitWait(resp *http.Response) (time.Duration, bool) {
+	if isGraphQLRateLimit(resp) {
+		if reset, ok := rateLimitReset(resp); ok {
+			return max(time.Until(reset), 0) + time.Second, true
+		}
+	}
+
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if at, err := http.ParseTime(retryAfter); err == nil {
+			return time.Until(at), true
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, ok := rateLimitReset(resp); ok {
+			// Allow for clock skew between the client and the server
+			return max(time.Until(reset), 0) + time.Second, true
+		}
+	}
+
+	if isSecondaryRateLimit(resp) {
+		return secondaryRateLimitWait, true
+	}
+
+	return 0, false
+}
+
+func rateLimitReset(resp *http.Response) (time.Time, bool) {
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(reset, 0), true
+}
+
+// isSecondaryRateLimit inspects the body of a 403 or 429 for the secondary
+// rate limit message, restoring the body so callers can still read it.
+func isSecondaryRateLimit(resp *http.Response) bool {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close() // nolint:errcheck
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(body)), "secondary rate limit")
+}
+
+// isGraphQLRateLimit reports whether resp is a GraphQL response for an
+// exhausted primary rate limit, which GitHub sends as a 200 whose errors
+// have the RATE_LIMITED type. The query was not run, and the GraphQL
+// client only sends read-only queries, so it is safe to retry.
+func isGraphQLRateLimit(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return false
+	}
+	if resp.Request == nil || !strings.HasSuffix(resp.Request.URL.Path, "/graphql") {
+		return false
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close() // nolint:errcheck
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(body), `"RATE_LIMITED"`)
+}
+
+func isGatewayError(statusCode int) bool {
+	return statusCode == http.StatusBadGateway ||
+		statusCode == http.StatusServiceUnavailable ||
+		statusCode == http.StatusGatewayTimeout
+}
+
+func isIdempotent(req *http.Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+// backoff returns an exponential delay with equal jitter for the given retry:
+// a random duration between half the ceiling and the ceiling, which doubles
+// with each retry up to backoffMax.
+func backoff(retry int) time.Duration {
+	ceiling := min(backoffBase<<(retry-1), backoffMax)
+	return ceiling/2 + rand.N(ceiling/2+1)
+}
+
+// rewindRequest returns a copy of req with a fresh body so it can be sent again.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	attempt := req.Clone(req.Context())
+	attempt.Body = body
+	return attempt, nil
+}
+
+func drainBody(resp *http.Response) {
+	io.Copy(io.Discard, resp.Body) // nolint:errcheck
+	resp.Body.Close()              // nolint:errcheck
+}
+
+func sleepContext(req *http.Request, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
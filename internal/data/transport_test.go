@@ -0,0 +1,149 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+	"github.com/katiem0/gh-export-secrets/internal/fakegithub"
+)
+
+func TestGraphQLRateLimitIsRetried(t *testing.T) {
+	server := fakegithub.NewServer(&fakegithub.Organization{
+		Login:        "acme",
+		Repositories: []fakegithub.Repository{{ID: 1, Name: "api", Visibility: "PRIVATE"}},
+	})
+	defer server.Close()
+	server.RateLimit(1)
+
+	g, err := server.NewAPIGetter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := g.GetReposList(context.Background(), "acme", nil)
+	if err != nil {
+		t.Fatalf("GetReposList() error = %v", err)
+	}
+	if got := len(query.Organization.Repositories.Nodes); got != 1 {
+		t.Errorf("GetReposList() returned %d repositories, want 1", got)
+	}
+	if got := len(server.Requests()); got != 2 {
+		t.Errorf("server received %d requests, want 2", got)
+	}
+}
+
+func TestRESTRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		inject       func(*fakegithub.Server)
+		wantErr      error
+		wantRequests int
+		minElapsed   time.Duration
+	}{
+		{
+			name:         "primary limit waits for reset",
+			inject:       func(s *fakegithub.Server) { s.RateLimit(1) },
+			wantRequests: 2,
+			minElapsed:   time.Second,
+		},
+		{
+			name: "secondary limit honors Retry-After",
+			inject: func(s *fakegithub.Server) {
+				s.RetryAfter = 1
+				s.SecondaryRateLimit(1)
+			},
+			wantRequests: 2,
+			minElapsed:   time.Second,
+		},
+		{
+			name:         "secondary limit without delay",
+			inject:       func(s *fakegithub.Server) { s.SecondaryRateLimit(2) },
+			wantRequests: 3,
+		},
+		{
+			name:         "502 is retried",
+			inject:       func(s *fakegithub.Server) { s.ServerError(http.StatusBadGateway, 1) },
+			wantRequests: 2,
+		},
+		{
+			name:         "503 is retried",
+			inject:       func(s *fakegithub.Server) { s.ServerError(http.StatusServiceUnavailable, 2) },
+			wantRequests: 3,
+		},
+		{
+			name:         "504 is retried up to the cap",
+			inject:       func(s *fakegithub.Server) { s.ServerError(http.StatusGatewayTimeout, data.MaxServerRetries) },
+			wantRequests: data.MaxServerRetries + 1,
+		},
+		{
+			name:         "gives up after the cap",
+			inject:       func(s *fakegithub.Server) { s.ServerError(http.StatusServiceUnavailable, data.MaxServerRetries+1) },
+			wantErr:      data.ErrServer,
+			wantRequests: data.MaxServerRetries + 1,
+		},
+		{
+			name:         "500 is not retried",
+			inject:       func(s *fakegithub.Server) { s.ServerError(http.StatusInternalServerError, 1) },
+			wantErr:      data.ErrServer,
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data.SetBackoffBase(t, time.Millisecond)
+			server := fakegithub.NewServer(&fakegithub.Organization{Login: "acme", ActionsSecrets: secrets(1)})
+			defer server.Close()
+			tt.inject(server)
+
+			g, err := server.NewAPIGetter()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			got, err := g.GetOrgActionSecrets(context.Background(), "acme")
+			elapsed := time.Since(start)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetOrgActionSecrets() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("GetOrgActionSecrets() error = %v", err)
+			} else if len(got) != 1 {
+				t.Errorf("GetOrgActionSecrets() returned %d secrets, want 1", len(got))
+			}
+			if got := len(server.Requests()); got != tt.wantRequests {
+				t.Errorf("server received %d requests, want %d", got, tt.wantRequests)
+			}
+			if elapsed < tt.minElapsed {
+				t.Errorf("GetOrgActionSecrets() returned after %s, want at least %s", elapsed, tt.minElapsed)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{retry: 1, min: 500 * time.Millisecond, max: time.Second},
+		{retry: 2, min: time.Second, max: 2 * time.Second},
+		{retry: 3, min: 2 * time.Second, max: 4 * time.Second},
+		{retry: 6, min: 15 * time.Second, max: 30 * time.Second},
+		{retry: 10, min: 15 * time.Second, max: 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 100 {
+			if got := data.Backoff(tt.retry); got < tt.min || got > tt.max {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.retry, got, tt.min, tt.max)
+			}
+		}
+	}
+}
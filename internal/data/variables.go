@@ -0,0 +1,48 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+func (g *APIGetter) GetOrgActionVariables(ctx context.Context, owner string) ([]Variable, error) {
+	url := fmt.Sprintf("orgs/%s/actions/variables", owner)
+
+	variables, err := getAllPages[Variable](ctx, g, url, "variables")
+	if err != nil {
+		return nil, err
+	}
+	return variables, nil
+}
+
+func (g *APIGetter) GetRepoActionVariables(ctx context.Context, owner string, repo string) ([]Variable, error) {
+	url := fmt.Sprintf("repos/%s/%s/actions/variables", owner, repo)
+
+	variables, err := getAllPages[Variable](ctx, g, url, "variables")
+	if err != nil {
+		return nil, err
+	}
+	return variables, nil
+}
+
+func (g *APIGetter) GetScopedOrgActionVariables(ctx context.Context, owner string, variable string) ([]ScopedRepository, error) {
+	url := fmt.Sprintf("orgs/%s/actions/variables/%s/repositories", owner, variable)
+
+	repositories, err := getAllPages[ScopedRepository](ctx, g, url, "repositories")
+	if err != nil {
+		return nil, err
+	}
+	return repositories, nil
+}
+
+func (g *APIGetter) GetEnvironmentVariables(ctx context.Context, owner string, repo string, environment string) ([]Variable, error) {
+	envName := url.PathEscape(environment)
+	url := fmt.Sprintf("repos/%s/%s/environments/%s/variables", owner, repo, envName)
+
+	variables, err := getAllPages[Variable](ctx, g, url, "variables")
+	if err != nil {
+		return nil, err
+	}
+	return variables, nil
+}
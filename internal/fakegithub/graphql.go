@@ -0,0 +1,118 @@
+package fakegithub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphQLRepository struct {
+	DatabaseID int       `json:"databaseId"`
+	Name       string    `json:"name"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	Visibility string    `json:"visibility"`
+}
+
+// handleGraphQL answers the getRepos and getRepo queries issued by
+// data.APIGetter. Repository cursors are the offset of the next page.
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var request graphQLRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	owner, _ := request.Variables["owner"].(string)
+
+	switch {
+	case strings.Contains(request.Query, "organization("):
+		s.handleReposQuery(w, owner, request.Variables["endCursor"])
+	case strings.Contains(request.Query, "repository("):
+		name, _ := request.Variables["name"].(string)
+		s.handleRepoQuery(w, owner, name)
+	default:
+		writeGraphQLError(w, "", fmt.Sprintf("Unsupported query: %s", request.Query))
+	}
+}
+
+func (s *Server) handleReposQuery(w http.ResponseWriter, owner string, endCursor interface{}) {
+	if !strings.EqualFold(owner, s.org.Login) {
+		writeGraphQLError(w, "NOT_FOUND", fmt.Sprintf("Could not resolve to an Organization with the login of '%s'.", owner))
+		return
+	}
+
+	start := 0
+	if cursor, ok := endCursor.(string); ok && cursor != "" {
+		start, _ = strconv.Atoi(cursor)
+	}
+
+	s.mu.Lock()
+	pageSize := min(s.MaxPerPage, maxPerPage)
+	s.mu.Unlock()
+
+	start = min(start, len(s.org.Repositories))
+	end := min(start+pageSize, len(s.org.Repositories))
+
+	nodes := []graphQLRepository{}
+	for _, repo := range s.org.Repositories[start:end] {
+		nodes = append(nodes, toGraphQLRepository(repo))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"organization": map[string]interface{}{
+				"repositories": map[string]interface{}{
+					"totalCount": len(s.org.Repositories),
+					"nodes":      nodes,
+					"pageInfo": map[string]interface{}{
+						"endCursor":   strconv.Itoa(end),
+						"hasNextPage": end < len(s.org.Repositories),
+					},
+				},
+			},
+		},
+	})
+}
+
+func (s *Server) handleRepoQuery(w http.ResponseWriter, owner string, name string) {
+	repo, ok := s.org.repository(name)
+	if !strings.EqualFold(owner, s.org.Login) || !ok {
+		writeGraphQLError(w, "NOT_FOUND", fmt.Sprintf("Could not resolve to a Repository with the name '%s/%s'.", owner, name))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"repository": toGraphQLRepository(*repo),
+		},
+	})
+}
+
+func toGraphQLRepository(repo Repository) graphQLRepository {
+	return graphQLRepository{
+		DatabaseID: repo.ID,
+		Name:       repo.Name,
+		UpdatedAt:  repo.UpdatedAt,
+		Visibility: strings.ToUpper(repo.Visibility),
+	}
+}
+
+func writeGraphQLError(w http.ResponseWriter, errorType string, message string) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": nil,
+		"errors": []map[string]interface{}{
+			{
+				"type":    errorType,
+				"message": message,
+			},
+		},
+	})
+}
@@ -0,0 +1,107 @@
+// Package fakegithub serves the subset of the GitHub REST and GraphQL APIs
+// used by gh-export-secrets from an in-memory model, so collection can be
+// exercised end to end without a real organization.
+package fakegithub
+
+import "time"
+
+// Organization is the in-memory model served by Server.
+type Organization struct {
+	Login             string
+	Repositories      []Repository
+	ActionsSecrets    []Secret
+	DependabotSecrets []Secret
+	CodespacesSecrets []Secret
+	Variables         []Variable
+}
+
+// Repository is a repository in the organization. Visibility uses the
+// GraphQL values PUBLIC, PRIVATE or INTERNAL.
+type Repository struct {
+	ID                int
+	Name              string
+	Visibility        string
+	UpdatedAt         time.Time
+	ActionsSecrets    []Secret
+	DependabotSecrets []Secret
+	CodespacesSecrets []Secret
+	Variables         []Variable
+	Environments      []Environment
+	// Files maps the path of each file on the default branch, such as
+	// ".github/workflows/ci.yml", to its content.
+	Files map[string]string
+	// SecurityUpdates is set when Dependabot security updates are enabled
+	// in the settings of the repository.
+	SecurityUpdates bool
+}
+
+// Environment is a deployment environment of a repository.
+type Environment struct {
+	ID        int
+	Name      string
+	Secrets   []Secret
+	Variables []Variable
+}
+
+// Secret is an organization, repository or environment secret.
+// SelectedRepositories names the repositories an organization secret with
+// selected visibility is scoped to.
+type Secret struct {
+	Name                 string
+	Visibility           string
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	SelectedRepositories []string
+}
+
+// Variable is an organization, repository or environment Actions variable.
+type Variable struct {
+	Name                 string
+	Value                string
+	Visibility           string
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	SelectedRepositories []string
+}
+
+func (o *Organization) repository(name string) (*Repository, bool) {
+	for i := range o.Repositories {
+		if o.Repositories[i].Name == name {
+			return &o.Repositories[i], true
+		}
+	}
+	return nil, false
+}
+
+func (r *Repository) environment(name string) (*Environment, bool) {
+	for i := range r.Environments {
+		if r.Environments[i].Name == name {
+			return &r.Environments[i], true
+		}
+	}
+	return nil, false
+}
+
+func (r *Repository) secrets(app string) []Secret {
+	switch app {
+	case "actions":
+		return r.ActionsSecrets
+	case "dependabot":
+		return r.DependabotSecrets
+	case "codespaces":
+		return r.CodespacesSecrets
+	}
+	return nil
+}
+
+func (o *Organization) secrets(app string) []Secret {
+	switch app {
+	case "actions":
+		return o.ActionsSecrets
+	case "dependabot":
+		return o.DependabotSecrets
+	case "codespaces":
+		return o.CodespacesSecrets
+	}
+	return nil
+}
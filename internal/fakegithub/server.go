@@ -0,0 +1,535 @@
+package fakegithub
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	pathpkg "path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/katiem0/gh-export-secrets/internal/data"
+)
+
+const (
+	// apiURL is the base of Link headers, so follow-up requests pass through
+	// Transport the same way as the first request.
+	apiURL = "https://api.github.com"
+
+	defaultPerPage = 30
+	maxPerPage     = 100
+)
+
+// Server is an httptest.Server that answers the REST and GraphQL requests
+// made by data.APIGetter from an Organization model.
+type Server struct {
+	*httptest.Server
+
+	// MaxPerPage caps the page size of REST list endpoints and GraphQL
+	// repository pages. Lower it to exercise pagination with small models.
+	MaxPerPage int
+	// StripLinks leaves the Link header out of REST list responses, as some
+	// proxies do, so that only total_count tells a client about more pages.
+	StripLinks bool
+	// RateLimitReset is the reset time reported by responses injected with
+	// RateLimit. When zero, the limit resets immediately.
+	RateLimitReset time.Time
+	// RetryAfter is the Retry-After header, in seconds, of responses
+	// injected with SecondaryRateLimit.
+	RetryAfter int
+
+	mu              sync.Mutex
+	org             *Organization
+	forbidden       map[string]bool
+	rateLimited     int
+	secondaryLimits int
+	serverErrors    int
+	serverStatus    int
+	requests        []string
+}
+
+// NewServer starts a server for org. Call Close when done.
+func NewServer(org *Organization) *Server {
+	s := &Server{
+		MaxPerPage: maxPerPage,
+		org:        org,
+		forbidden:  map[string]bool{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /graphql", s.handleGraphQL)
+	mux.HandleFunc("GET /orgs/{org}/{app}/secrets", s.handleOrgSecrets)
+	mux.HandleFunc("GET /orgs/{org}/{app}/secrets/{name}/repositories", s.handleOrgSecretRepositories)
+	mux.HandleFunc("GET /orgs/{org}/actions/variables", s.handleOrgVariables)
+	mux.HandleFunc("GET /orgs/{org}/actions/variables/{name}/repositories", s.handleOrgVariableRepositories)
+	for _, app := range []string{"actions", "dependabot", "codespaces"} {
+		mux.HandleFunc("GET /repos/{org}/{repo}/"+app+"/secrets", s.handleRepoSecrets(app))
+	}
+	mux.HandleFunc("GET /repos/{org}/{repo}/actions/variables", s.handleRepoVariables)
+	mux.HandleFunc("GET /repos/{org}/{repo}/environments", s.handleEnvironments)
+	mux.HandleFunc("GET /repos/{org}/{repo}/environments/{env}/secrets", s.handleEnvironmentSecrets)
+	mux.HandleFunc("GET /repos/{org}/{repo}/environments/{env}/variables", s.handleEnvironmentVariables)
+	mux.HandleFunc("GET /repos/{org}/{repo}/contents/{path...}", s.handleContents)
+	mux.HandleFunc("GET /repos/{org}/{repo}/automated-security-fixes", s.handleSecurityUpdates)
+
+	s.Server = httptest.NewServer(s.middleware(mux))
+	return s
+}
+
+// Forbid makes requests for path, e.g. "/repos/org/repo/dependabot/secrets",
+// respond with 403 Forbidden.
+func (s *Server) Forbid(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forbidden[path] = true
+}
+
+// RateLimit makes the next n requests respond as if the primary rate limit
+// is exhausted, until RateLimitReset.
+func (s *Server) RateLimit(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimited = n
+}
+
+// SecondaryRateLimit makes the next n requests respond with a secondary
+// rate limit and a Retry-After of RetryAfter seconds.
+func (s *Server) SecondaryRateLimit(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secondaryLimits = n
+}
+
+// ServerError makes the next n requests respond with status, such as
+// http.StatusBadGateway.
+func (s *Server) ServerError(status int, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.serverStatus = status
+	s.serverErrors = n
+}
+
+// Requests returns the method and URI, such as
+// "GET /orgs/acme/actions/secrets?page=2&per_page=2", of every request
+// received so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Transport returns a RoundTripper that sends every request to the server,
+// regardless of the host the client was configured with.
+func (s *Server) Transport() http.RoundTripper {
+	target, _ := url.Parse(s.URL)
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		req.Host = ""
+		return http.DefaultTransport.RoundTrip(req)
+	})
+}
+
+// NewAPIGetter returns an APIGetter whose clients talk to the server through
+// the same rate limit aware transport used against GitHub.
+func (s *Server) NewAPIGetter() (*data.APIGetter, error) {
+	transport := data.NewRateLimitTransport(s.Transport())
+
+	gqlClient, err := api.NewGraphQLClient(api.ClientOptions{
+		Host:         "github.com",
+		AuthToken:    "fake-token",
+		Transport:    transport,
+		LogIgnoreEnv: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	restClient, err := api.NewRESTClient(api.ClientOptions{
+		Host:         "github.com",
+		AuthToken:    "fake-token",
+		Transport:    transport,
+		LogIgnoreEnv: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return data.NewAPIGetter(gqlClient, restClient), nil
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// middleware records requests and injects forbidden, rate limit and server
+// error responses.
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+		forbidden := s.forbidden[r.URL.Path]
+		rateLimited := s.rateLimited > 0
+		if rateLimited {
+			s.rateLimited--
+		}
+		secondaryLimited := !rateLimited && s.secondaryLimits > 0
+		if secondaryLimited {
+			s.secondaryLimits--
+		}
+		serverError := !rateLimited && !secondaryLimited && s.serverErrors > 0
+		if serverError {
+			s.serverErrors--
+		}
+		serverStatus := s.serverStatus
+		reset := s.RateLimitReset
+		retryAfter := s.RetryAfter
+		s.mu.Unlock()
+		if reset.IsZero() {
+			reset = time.Now()
+		}
+
+		switch {
+		case rateLimited && r.URL.Path == "/graphql":
+			// GraphQL reports an exhausted primary limit in a 200 response
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"data": nil,
+				"errors": []map[string]string{
+					{"type": "RATE_LIMITED", "message": "API rate limit exceeded"},
+				},
+			})
+		case rateLimited:
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			writeError(w, http.StatusForbidden, "API rate limit exceeded")
+		case secondaryLimited:
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeError(w, http.StatusTooManyRequests, "You have exceeded a secondary rate limit")
+		case serverError:
+			writeError(w, serverStatus, http.StatusText(serverStatus))
+		case forbidden:
+			writeError(w, http.StatusForbidden, "Resource not accessible by integration")
+		default:
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "4999")
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func (s *Server) handleOrgSecrets(w http.ResponseWriter, r *http.Request) {
+	if !s.checkOrg(w, r) {
+		return
+	}
+	secrets, ok := s.orgSecrets(r.PathValue("app"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writePage(s, w, r, "secrets", toSecrets(secrets))
+}
+
+func (s *Server) handleOrgSecretRepositories(w http.ResponseWriter, r *http.Request) {
+	if !s.checkOrg(w, r) {
+		return
+	}
+	secrets, ok := s.orgSecrets(r.PathValue("app"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	for _, secret := range secrets {
+		if secret.Name == r.PathValue("name") {
+			writePage(s, w, r, "repositories", s.scopedRepositories(secret.SelectedRepositories))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) handleOrgVariables(w http.ResponseWriter, r *http.Request) {
+	if !s.checkOrg(w, r) {
+		return
+	}
+	writePage(s, w, r, "variables", toVariables(s.org.Variables))
+}
+
+func (s *Server) handleOrgVariableRepositories(w http.ResponseWriter, r *http.Request) {
+	if !s.checkOrg(w, r) {
+		return
+	}
+	for _, variable := range s.org.Variables {
+		if variable.Name == r.PathValue("name") {
+			writePage(s, w, r, "repositories", s.scopedRepositories(variable.SelectedRepositories))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) handleRepoSecrets(app string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo, ok := s.repository(w, r)
+		if !ok {
+			return
+		}
+		writePage(s, w, r, "secrets", toSecrets(repo.secrets(app)))
+	}
+}
+
+func (s *Server) handleRepoVariables(w http.ResponseWriter, r *http.Request) {
+	repo, ok := s.repository(w, r)
+	if !ok {
+		return
+	}
+	writePage(s, w, r, "variables", toVariables(repo.Variables))
+}
+
+func (s *Server) handleEnvironments(w http.ResponseWriter, r *http.Request) {
+	repo, ok := s.repository(w, r)
+	if !ok {
+		return
+	}
+	environments := []data.Environment{}
+	for _, environment := range repo.Environments {
+		environments = append(environments, data.Environment{
+			ID:   environment.ID,
+			Name: environment.Name,
+		})
+	}
+	writePage(s, w, r, "environments", environments)
+}
+
+func (s *Server) handleEnvironmentSecrets(w http.ResponseWriter, r *http.Request) {
+	environment, ok := s.environment(w, r)
+	if !ok {
+		return
+	}
+	writePage(s, w, r, "secrets", toSecrets(environment.Secrets))
+}
+
+func (s *Server) handleEnvironmentVariables(w http.ResponseWriter, r *http.Request) {
+	environment, ok := s.environment(w, r)
+	if !ok {
+		return
+	}
+	writePage(s, w, r, "variables", toVariables(environment.Variables))
+}
+
+// handleContents returns a file with base64 content, or the entries of a
+// directory containing files.
+func (s *Server) handleSecurityUpdates(w http.ResponseWriter, r *http.Request) {
+	repo, ok := s.repository(w, r)
+	if !ok {
+		return
+	}
+	if !repo.SecurityUpdates {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"enabled": true, "paused": false})
+}
+
+func (s *Server) handleContents(w http.ResponseWriter, r *http.Request) {
+	repo, ok := s.repository(w, r)
+	if !ok {
+		return
+	}
+	path := strings.Trim(r.PathValue("path"), "/")
+
+	if content, ok := repo.Files[path]; ok {
+		writeJSON(w, http.StatusOK, data.FileContent{
+			Name:     pathpkg.Base(path),
+			Path:     path,
+			Type:     "file",
+			Encoding: "base64",
+			Content:  base64.StdEncoding.EncodeToString([]byte(content)),
+		})
+		return
+	}
+
+	seen := map[string]bool{}
+	entries := []data.ContentEntry{}
+	for filePath := range repo.Files {
+		rest, ok := strings.CutPrefix(filePath, path+"/")
+		if !ok {
+			continue
+		}
+		name, _, isDir := strings.Cut(rest, "/")
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		entry := data.ContentEntry{Name: name, Path: path + "/" + name, Type: "file"}
+		if isDir {
+			entry.Type = "dir"
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) checkOrg(w http.ResponseWriter, r *http.Request) bool {
+	if !strings.EqualFold(r.PathValue("org"), s.org.Login) {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return false
+	}
+	return true
+}
+
+func (s *Server) orgSecrets(app string) ([]Secret, bool) {
+	if app != "actions" && app != "dependabot" && app != "codespaces" {
+		return nil, false
+	}
+	return s.org.secrets(app), true
+}
+
+func (s *Server) repository(w http.ResponseWriter, r *http.Request) (*Repository, bool) {
+	if !s.checkOrg(w, r) {
+		return nil, false
+	}
+	repo, ok := s.org.repository(r.PathValue("repo"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return nil, false
+	}
+	return repo, true
+}
+
+func (s *Server) environment(w http.ResponseWriter, r *http.Request) (*Environment, bool) {
+	repo, ok := s.repository(w, r)
+	if !ok {
+		return nil, false
+	}
+	environment, ok := repo.environment(r.PathValue("env"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return nil, false
+	}
+	return environment, true
+}
+
+func (s *Server) scopedRepositories(names []string) []data.ScopedRepository {
+	repositories := []data.ScopedRepository{}
+	for _, name := range names {
+		repo, ok := s.org.repository(name)
+		if !ok {
+			continue
+		}
+		visibility := strings.ToLower(repo.Visibility)
+		repositories = append(repositories, data.ScopedRepository{
+			ID:         repo.ID,
+			Name:       repo.Name,
+			Private:    visibility != "public",
+			Visibility: visibility,
+		})
+	}
+	return repositories
+}
+
+func (s *Server) perPage(r *http.Request) int {
+	perPage := defaultPerPage
+	if value, err := strconv.Atoi(r.URL.Query().Get("per_page")); err == nil && value > 0 {
+		perPage = value
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return min(perPage, s.MaxPerPage, maxPerPage)
+}
+
+// writePage writes one page of items under key with total_count and a Link
+// header pointing at the next and last pages.
+func writePage[T any](s *Server, w http.ResponseWriter, r *http.Request, key string, items []T) {
+	perPage := s.perPage(r)
+	page := 1
+	if value, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && value > 0 {
+		page = value
+	}
+
+	start := min((page-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	lastPage := max((len(items)+perPage-1)/perPage, 1)
+
+	s.mu.Lock()
+	stripLinks := s.StripLinks
+	s.mu.Unlock()
+	if page < lastPage && !stripLinks {
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next", <%s>; rel="last"`,
+			pageLink(r, perPage, page+1), pageLink(r, perPage, lastPage)))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total_count": len(items),
+		key:           items[start:end],
+	})
+}
+
+func pageLink(r *http.Request, perPage int, page int) string {
+	query := r.URL.Query()
+	query.Set("per_page", strconv.Itoa(perPage))
+	query.Set("page", strconv.Itoa(page))
+	return fmt.Sprintf("%s%s?%s", apiURL, r.URL.EscapedPath(), query.Encode())
+}
+
+func toSecrets(secrets []Secret) []data.Secret {
+	converted := []data.Secret{}
+	for _, secret := range secrets {
+		converted = append(converted, data.Secret{
+			Name:       secret.Name,
+			CreatedAt:  secret.CreatedAt,
+			UpdatedAt:  secret.UpdatedAt,
+			Visibility: secret.Visibility,
+		})
+	}
+	return converted
+}
+
+func toVariables(variables []Variable) []data.Variable {
+	converted := []data.Variable{}
+	for _, variable := range variables {
+		converted = append(converted, data.Variable{
+			Name:       variable.Name,
+			Value:      variable.Value,
+			CreatedAt:  variable.CreatedAt,
+			UpdatedAt:  variable.UpdatedAt,
+			Visibility: variable.Visibility,
+		})
+	}
+	return converted
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body) // nolint:errcheck
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{
+		"message":           message,
+		"documentation_url": "https://docs.github.com/rest",
+	})
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+)
+
+type csvWriter struct {
+	writer *csv.Writer
+	opts   Options
+}
+
+func newCSVWriter(w io.Writer, opts Options) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+
+	header := []string{
+		"SecretLevel",
+		"SecretType",
+		"SecretName",
+		"SecretAccess",
+		"RepositoryName",
+		"RepositoryID",
+		"RepositoryVisibility",
+		"SecretCreatedAt",
+		"SecretUpdatedAt",
+	}
+	if opts.IncludeValues {
+		header = append(header, "VariableValue")
+	}
+	if opts.IncludeUsage {
+		header = append(header, "Referenced", "ReferencedBy", "IndirectConsumers")
+	}
+
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	writer.Flush()
+
+	return &csvWriter{
+		writer: writer,
+		opts:   opts,
+	}, nil
+}
+
+func (c *csvWriter) Write(export data.SecretExport) error {
+	repositoryID := ""
+	if export.RepositoryID != 0 {
+		repositoryID = strconv.Itoa(export.RepositoryID)
+	}
+
+	record := []string{
+		export.SecretLevel,
+		export.SecretType,
+		export.SecretName,
+		export.SecretAccess,
+		export.RepositoryName,
+		repositoryID,
+		export.RepositoryVisibility,
+		FormatTime(export.SecretCreatedAt),
+		FormatTime(export.SecretUpdatedAt),
+	}
+	if c.opts.IncludeValues {
+		record = append(record, export.VariableValue)
+	}
+	if c.opts.IncludeUsage {
+		referenced := ""
+		if export.Referenced != nil {
+			referenced = strconv.FormatBool(*export.Referenced)
+		}
+		record = append(record, referenced, strings.Join(export.ReferencedBy, ";"), strings.Join(export.IndirectConsumers, ";"))
+	}
+	if err := c.writer.Write(record); err != nil {
+		return err
+	}
+
+	// Flush every row so the report can be tailed while a scan runs
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
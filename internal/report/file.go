@@ -0,0 +1,102 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// File is a report written to a temporary file in the destination directory.
+// The temporary file is only renamed to the destination by Commit, so a run
+// that fails part way through never leaves a partial report behind.
+type File struct {
+	*os.File
+	path      string
+	force     bool
+	committed bool
+}
+
+// CreateFile prepares a report for path. Unless force is set, it is an error
+// for path to already exist.
+func CreateFile(path string, force bool) (*File, error) {
+	if err := checkOverwrite(path, force); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), fmt.Sprintf(".%s.*.tmp", filepath.Base(path)))
+	if err != nil {
+		return nil, err
+	}
+	if err = tmp.Chmod(0644); err != nil {
+		tmp.Close()           // nolint:errcheck
+		os.Remove(tmp.Name()) // nolint:errcheck
+		return nil, err
+	}
+
+	return &File{
+		File:  tmp,
+		path:  path,
+		force: force,
+	}, nil
+}
+
+// Commit syncs the temporary file and atomically moves it to the
+// destination. Unless force is set, it is linked to the destination, which
+// fails rather than replacing a file created since CreateFile.
+func (f *File) Commit() error {
+	if err := f.File.Sync(); err != nil {
+		return err
+	}
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	if f.force {
+		if err := os.Rename(f.File.Name(), f.path); err != nil {
+			return err
+		}
+		f.committed = true
+		return nil
+	}
+
+	if err := os.Link(f.File.Name(), f.path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return existsError(f.path)
+		}
+		return err
+	}
+	f.committed = true
+	return os.Remove(f.File.Name())
+}
+
+// Close removes the temporary file unless the report has been committed.
+// It is safe to call after Commit.
+func (f *File) Close() error {
+	if f.committed {
+		return nil
+	}
+	f.File.Close() // nolint:errcheck
+	err := os.Remove(f.File.Name())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func checkOverwrite(path string, force bool) error {
+	if force {
+		return nil
+	}
+	_, err := os.Stat(path)
+	if err == nil {
+		return existsError(path)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func existsError(path string) error {
+	return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+}
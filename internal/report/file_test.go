@@ -0,0 +1,120 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tempFiles returns the temporary report files left in dir.
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestCreateFileRefusesExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CreateFile(path, false); err == nil {
+		t.Fatal("CreateFile() error = nil, want an error for an existing file")
+	}
+
+	f, err := CreateFile(path, true)
+	if err != nil {
+		t.Fatalf("CreateFile() with force error = %v", err)
+	}
+	defer f.Close() // nolint:errcheck
+	if _, err = f.WriteString("new"); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "new" {
+		t.Errorf("report = %q, want %q", got, "new")
+	}
+}
+
+func TestCommitDoesNotReplaceFileCreatedMeanwhile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.csv")
+
+	f, err := CreateFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() // nolint:errcheck
+	if _, err = f.WriteString("new"); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path, []byte("other"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.Commit(); err == nil {
+		t.Fatal("Commit() error = nil, want an error for a file created since CreateFile")
+	}
+	if got, _ := os.ReadFile(path); string(got) != "other" {
+		t.Errorf("report = %q, want the file created meanwhile left alone", got)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if left := tempFiles(t, dir); len(left) != 0 {
+		t.Errorf("temporary files left behind: %v", left)
+	}
+}
+
+func TestCommitMovesReport(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.csv")
+
+	f, err := CreateFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteString("report"); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("Close() after Commit() error = %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "report" {
+		t.Errorf("report = %q, want %q", got, "report")
+	}
+	if left := tempFiles(t, dir); len(left) != 0 {
+		t.Errorf("temporary files left behind: %v", left)
+	}
+}
+
+func TestCloseRemovesUncommittedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.csv")
+
+	f, err := CreateFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteString("partial"); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("report exists after Close() without Commit(), stat error = %v", err)
+	}
+	if left := tempFiles(t, dir); len(left) != 0 {
+		t.Errorf("temporary files left behind: %v", left)
+	}
+}
@@ -0,0 +1,78 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+)
+
+// jsonWriter streams a pretty printed JSON array, writing each element as
+// it is received rather than buffering the whole report.
+type jsonWriter struct {
+	w             io.Writer
+	includeValues bool
+	count         int
+}
+
+func newJSONWriter(w io.Writer, includeValues bool) *jsonWriter {
+	return &jsonWriter{
+		w:             w,
+		includeValues: includeValues,
+	}
+}
+
+func (j *jsonWriter) Write(export data.SecretExport) error {
+	if !j.includeValues {
+		export.VariableValue = ""
+	}
+	record, err := json.MarshalIndent(export, "  ", "  ")
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n  "
+	if j.count == 0 {
+		separator = "[\n  "
+	}
+	j.count++
+
+	if _, err = io.WriteString(j.w, separator); err != nil {
+		return err
+	}
+	_, err = j.w.Write(record)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	closing := "\n]\n"
+	if j.count == 0 {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(j.w, closing)
+	return err
+}
+
+// ndjsonWriter writes one compact JSON object per line.
+type ndjsonWriter struct {
+	encoder       *json.Encoder
+	includeValues bool
+}
+
+func newNDJSONWriter(w io.Writer, includeValues bool) *ndjsonWriter {
+	return &ndjsonWriter{
+		encoder:       json.NewEncoder(w),
+		includeValues: includeValues,
+	}
+}
+
+func (n *ndjsonWriter) Write(export data.SecretExport) error {
+	if !n.includeValues {
+		export.VariableValue = ""
+	}
+	return n.encoder.Encode(export)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,160 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+)
+
+var testExports = []data.SecretExport{
+	{
+		SecretLevel: "Repository", SecretType: "Actions", SecretName: "DEPLOY_KEY", SecretAccess: "RepoOnly",
+		RepositoryName: "api", RepositoryID: 102, RepositoryVisibility: "private",
+		SecretCreatedAt: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		SecretUpdatedAt: time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC),
+	},
+	{
+		SecretLevel: "Repository", SecretType: "Variables", SecretName: "LOG_LEVEL", SecretAccess: "RepoOnly",
+		RepositoryName: "api", RepositoryID: 102, RepositoryVisibility: "private",
+		SecretCreatedAt: time.Date(2024, 1, 7, 12, 0, 0, 0, time.UTC),
+		SecretUpdatedAt: time.Date(2024, 1, 7, 12, 0, 0, 0, time.UTC),
+		VariableValue:   "debug",
+	},
+}
+
+func TestWriters(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		opts    Options
+		exports []data.SecretExport
+		want    string
+	}{
+		{
+			name:   "csv empty",
+			format: "csv",
+			want:   "SecretLevel,SecretType,SecretName,SecretAccess,RepositoryName,RepositoryID,RepositoryVisibility,SecretCreatedAt,SecretUpdatedAt\n",
+		},
+		{
+			name:    "csv drops values",
+			format:  "csv",
+			exports: testExports,
+			want: "SecretLevel,SecretType,SecretName,SecretAccess,RepositoryName,RepositoryID,RepositoryVisibility,SecretCreatedAt,SecretUpdatedAt\n" +
+				"Repository,Actions,DEPLOY_KEY,RepoOnly,api,102,private,2024-01-01T12:00:00Z,2024-02-01T12:00:00Z\n" +
+				"Repository,Variables,LOG_LEVEL,RepoOnly,api,102,private,2024-01-07T12:00:00Z,2024-01-07T12:00:00Z\n",
+		},
+		{
+			name:    "csv includes values",
+			format:  "csv",
+			opts:    Options{IncludeValues: true},
+			exports: testExports[1:],
+			want: "SecretLevel,SecretType,SecretName,SecretAccess,RepositoryName,RepositoryID,RepositoryVisibility,SecretCreatedAt,SecretUpdatedAt,VariableValue\n" +
+				"Repository,Variables,LOG_LEVEL,RepoOnly,api,102,private,2024-01-07T12:00:00Z,2024-01-07T12:00:00Z,debug\n",
+		},
+		{
+			name:   "json empty",
+			format: "json",
+			want:   "[]\n",
+		},
+		{
+			name:    "json drops values",
+			format:  "json",
+			exports: testExports,
+			want: `[
+  {
+    "secret_level": "Repository",
+    "secret_type": "Actions",
+    "secret_name": "DEPLOY_KEY",
+    "secret_access": "RepoOnly",
+    "repository_name": "api",
+    "repository_id": 102,
+    "repository_visibility": "private",
+    "secret_created_at": "2024-01-01T12:00:00Z",
+    "secret_updated_at": "2024-02-01T12:00:00Z"
+  },
+  {
+    "secret_level": "Repository",
+    "secret_type": "Variables",
+    "secret_name": "LOG_LEVEL",
+    "secret_access": "RepoOnly",
+    "repository_name": "api",
+    "repository_id": 102,
+    "repository_visibility": "private",
+    "secret_created_at": "2024-01-07T12:00:00Z",
+    "secret_updated_at": "2024-01-07T12:00:00Z"
+  }
+]
+`,
+		},
+		{
+			name:    "json includes values",
+			format:  "json",
+			opts:    Options{IncludeValues: true},
+			exports: testExports[1:],
+			want: `[
+  {
+    "secret_level": "Repository",
+    "secret_type": "Variables",
+    "secret_name": "LOG_LEVEL",
+    "secret_access": "RepoOnly",
+    "repository_name": "api",
+    "repository_id": 102,
+    "repository_visibility": "private",
+    "secret_created_at": "2024-01-07T12:00:00Z",
+    "secret_updated_at": "2024-01-07T12:00:00Z",
+    "variable_value": "debug"
+  }
+]
+`,
+		},
+		{
+			name:   "ndjson empty",
+			format: "ndjson",
+		},
+		{
+			name:    "ndjson drops values",
+			format:  "ndjson",
+			exports: testExports,
+			want: `{"secret_level":"Repository","secret_type":"Actions","secret_name":"DEPLOY_KEY","secret_access":"RepoOnly","repository_name":"api","repository_id":102,"repository_visibility":"private","secret_created_at":"2024-01-01T12:00:00Z","secret_updated_at":"2024-02-01T12:00:00Z"}
+{"secret_level":"Repository","secret_type":"Variables","secret_name":"LOG_LEVEL","secret_access":"RepoOnly","repository_name":"api","repository_id":102,"repository_visibility":"private","secret_created_at":"2024-01-07T12:00:00Z","secret_updated_at":"2024-01-07T12:00:00Z"}
+`,
+		},
+		{
+			name:    "ndjson includes values",
+			format:  "ndjson",
+			opts:    Options{IncludeValues: true},
+			exports: testExports[1:],
+			want: `{"secret_level":"Repository","secret_type":"Variables","secret_name":"LOG_LEVEL","secret_access":"RepoOnly","repository_name":"api","repository_id":102,"repository_visibility":"private","secret_created_at":"2024-01-07T12:00:00Z","secret_updated_at":"2024-01-07T12:00:00Z","variable_value":"debug"}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(tt.format, &buf, tt.opts)
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+			for _, export := range tt.exports {
+				if err = w.Write(export); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err = w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("%s report mismatch:\n got:\n%s\nwant:\n%s", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWriterRejectsUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("xml", &bytes.Buffer{}, Options{}); err == nil {
+		t.Error("NewWriter() error = nil, want an error for an unsupported format")
+	}
+}
@@ -0,0 +1,67 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+)
+
+// Formats lists the supported values for --format.
+var Formats = []string{"csv", "json", "ndjson"}
+
+// Writer emits SecretExport records in a specific output format.
+// Close must be called once all records are written to flush any
+// buffered output and terminate the document.
+type Writer interface {
+	Write(export data.SecretExport) error
+	Close() error
+}
+
+// Options selects the optional columns of a report.
+type Options struct {
+	// IncludeValues adds the values of Actions variables.
+	IncludeValues bool
+	// IncludeUsage adds whether each secret is referenced, and where.
+	IncludeUsage bool
+}
+
+// NewWriter returns a Writer for format that writes to w.
+func NewWriter(format string, w io.Writer, opts Options) (Writer, error) {
+	if err := ValidateFormat(format); err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return newJSONWriter(w, opts.IncludeValues), nil
+	case "ndjson":
+		return newNDJSONWriter(w, opts.IncludeValues), nil
+	default:
+		return newCSVWriter(w, opts)
+	}
+}
+
+// ValidateFormat returns an error if format is not one of Formats.
+func ValidateFormat(format string) error {
+	if !slices.Contains(Formats, strings.ToLower(format)) {
+		return fmt.Errorf("unsupported format %q: expected one of {%s}", format, strings.Join(Formats, "|"))
+	}
+	return nil
+}
+
+// Extension returns the file extension used for format.
+func Extension(format string) string {
+	return strings.ToLower(format)
+}
+
+// FormatTime renders a timestamp for text reports, leaving unset values blank.
+func FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,71 @@
+package usage
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DependabotDir is the directory that holds a repository's Dependabot
+// configuration.
+const DependabotDir = ".github"
+
+// Registry is a private registry declared in the registries: section of a
+// Dependabot configuration.
+type Registry struct {
+	Name string
+	Type string
+	Path string
+	Line int
+	// References are the secrets its settings reference.
+	References []Reference
+}
+
+// IsDependabotFile reports whether name is a Dependabot configuration file
+// name.
+func IsDependabotFile(name string) bool {
+	return name == "dependabot.yml" || name == "dependabot.yaml"
+}
+
+// ParseDependabot returns the registries declared in the Dependabot
+// configuration at path, in the order they appear.
+func ParseDependabot(path string, content []byte) ([]Registry, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: configuration is not a mapping", path)
+	}
+
+	registries := mappingValue(root, "registries")
+	if registries == nil {
+		return nil, nil
+	}
+
+	var result []Registry
+	for i := 0; i+1 < len(registries.Content); i += 2 {
+		name, settings := registries.Content[i], registries.Content[i+1]
+		registry := Registry{
+			Name: name.Value,
+			Path: path,
+			Line: name.Line,
+		}
+		if registryType := mappingValue(settings, "type"); registryType != nil {
+			registry.Type = registryType.Value
+		}
+		for _, ref := range findReferences(content, path, settings, "", "") {
+			// Registries can only read secrets
+			if ref.Context == ContextSecrets {
+				registry.References = append(registry.References, ref)
+			}
+		}
+		result = append(result, registry)
+	}
+	return result, nil
+}
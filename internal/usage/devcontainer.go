@@ -0,0 +1,156 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Dev container configurations are read from DevcontainerDir and from its
+// immediate subdirectories.
+const (
+	DevcontainerDir  = ".devcontainer"
+	DevcontainerFile = "devcontainer.json"
+)
+
+// ParseDevcontainer returns the secrets recommended by the secrets: block
+// of the dev container configuration at path, in the order they appear.
+func ParseDevcontainer(path string, content []byte) ([]Reference, error) {
+	stripped := stripJSONC(content)
+	if len(bytes.TrimSpace(stripped)) == 0 {
+		return nil, nil
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(stripped, &document); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if _, ok := document.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("%s: configuration is not an object", path)
+	}
+
+	// The document is valid, so walk its tokens to keep the line of every
+	// key of the secrets object. The last secrets key wins, as it does
+	// when decoding.
+	decoder := json.NewDecoder(bytes.NewReader(stripped))
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var refs []Reference
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if key != "secrets" {
+			var value json.RawMessage
+			if err = decoder.Decode(&value); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			continue
+		}
+		if refs, err = secretKeys(path, stripped, decoder); err != nil {
+			return nil, err
+		}
+	}
+	return refs, nil
+}
+
+// secretKeys reads the value of the secrets key from decoder and returns a
+// reference for each of its keys. A value that is not an object has none.
+func secretKeys(path string, content []byte, decoder *json.Decoder) ([]Reference, error) {
+	var value json.RawMessage
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if !bytes.HasPrefix(value, []byte("{")) {
+		return nil, nil
+	}
+
+	// Walk the object on its own, offsetting by where it starts in content
+	start := decoder.InputOffset() - int64(len(value))
+	secrets := json.NewDecoder(bytes.NewReader(value))
+	if _, err := secrets.Token(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var refs []Reference
+	for secrets.More() {
+		key, err := secrets.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		name, _ := key.(string)
+		refs = append(refs, Reference{
+			Context: ContextSecrets,
+			Name:    name,
+			Path:    path,
+			Line:    lineAt(content, start+secrets.InputOffset()),
+		})
+
+		var setting json.RawMessage
+		if err = secrets.Decode(&setting); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return refs, nil
+}
+
+// lineAt returns the line of content that the byte at offset is on.
+func lineAt(content []byte, offset int64) int {
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
+
+// stripJSONC turns JSON with comments, as used by dev container
+// configurations, into JSON. Comments are replaced with spaces and trailing
+// commas are dropped, keeping the offset of everything else.
+func stripJSONC(content []byte) []byte {
+	out := make([]byte, len(content))
+	copy(out, content)
+
+	inString := false
+	lastComma := -1
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+			continue
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(out)
+			} else {
+				end += i + 4
+			}
+			for ; i < end; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+			continue
+		case c == ',':
+			lastComma = i
+			continue
+		case c == '}' || c == ']':
+			if lastComma >= 0 {
+				out[lastComma] = ' '
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			continue
+		}
+		lastComma = -1
+	}
+	return out
+}
@@ -0,0 +1,41 @@
+package usage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDevcontainer(t *testing.T) {
+	content := []byte(`// Dev container for the API
+{
+	"name": "api \"dev\"",
+	"image": "mcr.microsoft.com/devcontainers/go:1",
+	/* The URL escapes its slashes */
+	"customizations": {"docs": "https:\/\/example.com\/docs",},
+	"secrets": {
+		"NPM_TOKEN": {
+			"description": "Token for the private registry",
+			"documentationUrl": "https:\/\/example.com\/npm", // trailing comma
+		},
+		"DB_PASSWORD": {},
+	},
+}
+`)
+	refs, err := ParseDevcontainer(".devcontainer/devcontainer.json", content)
+	if err != nil {
+		t.Fatalf("ParseDevcontainer() error = %v", err)
+	}
+	want := []Reference{
+		{Context: ContextSecrets, Name: "NPM_TOKEN", Path: ".devcontainer/devcontainer.json", Line: 8},
+		{Context: ContextSecrets, Name: "DB_PASSWORD", Path: ".devcontainer/devcontainer.json", Line: 12},
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("ParseDevcontainer() = %+v, want %+v", refs, want)
+	}
+}
+
+func TestParseDevcontainerNotAnObject(t *testing.T) {
+	if _, err := ParseDevcontainer("devcontainer.json", []byte(`["secrets"]`)); err == nil {
+		t.Error("ParseDevcontainer() error = nil, want an error for an array")
+	}
+}
@@ -0,0 +1,339 @@
+// Package usage finds the secrets and variables that files in a repository
+// reference, such as GitHub Actions workflows.
+package usage
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Contexts of the expressions that reference a secret or variable.
+const (
+	ContextSecrets = "secrets"
+	ContextVars    = "vars"
+)
+
+// WorkflowsDir is the directory that holds a repository's workflows.
+const WorkflowsDir = ".github/workflows"
+
+// Reference is a use of a secret or variable in a repository file.
+type Reference struct {
+	// Context is ContextSecrets or ContextVars.
+	Context string
+	Name    string
+	Path    string
+	Line    int
+	// Environment is the deployment environment of the job the reference
+	// is in, if any. It may itself be an expression.
+	Environment string
+	// CalledOnly is set when the reference is in a workflow that only runs
+	// when called by other workflows, which pass in the secrets it reads.
+	CalledOnly bool
+}
+
+// Workflow is what a workflow file references and calls.
+type Workflow struct {
+	Path string
+	// Events lists the events that trigger the workflow.
+	Events []string
+	// Callable is set when the workflow is triggered by workflow_call.
+	Callable   bool
+	References []Reference
+	Calls      []Call
+}
+
+// Call is a job that calls a reusable workflow, e.g.
+// uses: org/repo/.github/workflows/deploy.yml@main.
+type Call struct {
+	// Path and Line locate the uses: key of the calling job.
+	Path string
+	Line int
+	Job  string
+	// Owner and Repository are empty when the called workflow is in the
+	// same repository, e.g. uses: ./.github/workflows/deploy.yml.
+	Owner      string
+	Repository string
+	Workflow   string
+	Ref        string
+	// Inherit is set by secrets: inherit, which passes every secret of
+	// the caller under its own name.
+	Inherit bool
+	Secrets []PassedSecret
+}
+
+// PassedSecret is an entry of an explicit secrets: map of a Call.
+type PassedSecret struct {
+	// Name is the secret name in the called workflow.
+	Name string
+	// From lists the caller secrets referenced by the value.
+	From []string
+}
+
+var (
+	expressionRE = regexp.MustCompile(`\$\{\{(.*?)\}\}`)
+	// Matches secrets.NAME as well as secrets['NAME'] and secrets["NAME"]
+	contextRE = regexp.MustCompile(`\b(secrets|vars)\s*(?:\.\s*([A-Za-z_][A-Za-z0-9_-]*)|\[\s*['"]([^'"]+)['"]\s*\])`)
+)
+
+// IsWorkflowFile reports whether name is a workflow file name.
+func IsWorkflowFile(name string) bool {
+	return strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml")
+}
+
+// ParseWorkflow returns the secrets and variables referenced by the
+// workflow at path, in the order they appear, and the reusable workflows it
+// calls. References are found in any expression, including in with:, env:
+// and run: values.
+func ParseWorkflow(path string, content []byte) (*Workflow, error) {
+	workflow := &Workflow{Path: path}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(document.Content) == 0 {
+		return workflow, nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: workflow is not a mapping", path)
+	}
+
+	// yaml.v3 decodes an unquoted on: key as the string "on"
+	workflow.Events = events(mappingValue(root, "on"))
+	workflow.Callable = slices.Contains(workflow.Events, "workflow_call")
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "on":
+			// Trigger definitions declare inputs and secrets, they do not read them
+			continue
+		case "jobs":
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				name, job := value.Content[j], value.Content[j+1]
+				workflow.References = append(workflow.References, findReferences(content, path, job, "", jobEnvironment(job))...)
+				if call, ok := parseCall(content, path, name.Value, job); ok {
+					workflow.Calls = append(workflow.Calls, call)
+				}
+			}
+		default:
+			workflow.References = append(workflow.References, findReferences(content, path, value, key.Value, "")...)
+		}
+	}
+
+	for i := range workflow.References {
+		workflow.References[i].CalledOnly = workflow.CalledOnly()
+	}
+	return workflow, nil
+}
+
+// events returns the events in the on: value of a workflow, which may be a
+// string, a list or a mapping.
+func events(on *yaml.Node) []string {
+	if on == nil {
+		return nil
+	}
+	var names []string
+	switch on.Kind {
+	case yaml.ScalarNode:
+		names = append(names, on.Value)
+	case yaml.SequenceNode:
+		for _, item := range on.Content {
+			names = append(names, item.Value)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(on.Content); i += 2 {
+			names = append(names, on.Content[i].Value)
+		}
+	}
+	return names
+}
+
+// CalledOnly reports whether the workflow only runs when called by another
+// workflow, and so only reads the secrets its callers pass it.
+func (w *Workflow) CalledOnly() bool {
+	return w.Callable && !slices.ContainsFunc(w.Events, func(event string) bool { return event != "workflow_call" })
+}
+
+// parseCall returns the reusable workflow called by a job, if any.
+func parseCall(source []byte, path string, jobName string, job *yaml.Node) (Call, bool) {
+	uses := mappingValue(job, "uses")
+	if uses == nil || uses.Kind != yaml.ScalarNode {
+		return Call{}, false
+	}
+
+	call := Call{
+		Path: path,
+		Line: uses.Line,
+		Job:  jobName,
+	}
+
+	target, ref, _ := strings.Cut(uses.Value, "@")
+	call.Ref = ref
+	if local, ok := strings.CutPrefix(target, "./"); ok {
+		call.Workflow = local
+	} else {
+		parts := strings.SplitN(target, "/", 3)
+		if len(parts) != 3 {
+			return Call{}, false
+		}
+		call.Owner, call.Repository, call.Workflow = parts[0], parts[1], parts[2]
+	}
+
+	secrets := mappingValue(job, "secrets")
+	switch {
+	case secrets == nil:
+	case secrets.Kind == yaml.ScalarNode:
+		call.Inherit = secrets.Value == "inherit"
+	case secrets.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(secrets.Content); i += 2 {
+			passed := PassedSecret{Name: secrets.Content[i].Value}
+			for _, ref := range scanScalar(source, secrets.Content[i+1], false) {
+				if ref.Context == ContextSecrets {
+					passed.From = append(passed.From, ref.Name)
+				}
+			}
+			call.Secrets = append(call.Secrets, passed)
+		}
+	}
+
+	return call, true
+}
+
+// jobEnvironment returns the environment a job deploys to, written either
+// as environment: name or environment: {name: name}.
+func jobEnvironment(job *yaml.Node) string {
+	environment := mappingValue(job, "environment")
+	if environment == nil {
+		return ""
+	}
+	if environment.Kind == yaml.MappingNode {
+		environment = mappingValue(environment, "name")
+		if environment == nil {
+			return ""
+		}
+	}
+	return environment.Value
+}
+
+// findReferences walks node, parsed from source, and returns the references
+// in its scalars. key is the mapping key node is the value of, if any.
+func findReferences(source []byte, path string, node *yaml.Node, key string, environment string) []Reference {
+	var refs []Reference
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		for _, match := range scanScalar(source, node, key == "if") {
+			match.Path = path
+			match.Environment = environment
+			refs = append(refs, match)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			refs = append(refs, findReferences(source, path, node.Content[i+1], node.Content[i].Value, environment)...)
+		}
+	case yaml.SequenceNode, yaml.DocumentNode:
+		for _, child := range node.Content {
+			refs = append(refs, findReferences(source, path, child, "", environment)...)
+		}
+	case yaml.AliasNode:
+		// Anchored content is reported where the anchor is defined
+	}
+
+	return refs
+}
+
+// scanScalar returns the references in the expressions of a scalar. The
+// value of an if: key is an expression even without ${{ }}.
+func scanScalar(source []byte, node *yaml.Node, bareExpression bool) []Reference {
+	var expressions [][]int
+	if bareExpression && !strings.Contains(node.Value, "${{") {
+		expressions = append(expressions, []int{0, len(node.Value)})
+	} else {
+		for _, span := range expressionRE.FindAllStringSubmatchIndex(node.Value, -1) {
+			expressions = append(expressions, span[2:4])
+		}
+	}
+
+	var refs []Reference
+	for _, expression := range expressions {
+		offset := expression[0]
+		for _, match := range contextRE.FindAllStringSubmatchIndex(node.Value[offset:expression[1]], -1) {
+			name := submatch(node.Value[offset:], match, 2)
+			if name == "" {
+				name = submatch(node.Value[offset:], match, 3)
+			}
+			refs = append(refs, Reference{
+				Context: submatch(node.Value[offset:], match, 1),
+				Name:    name,
+				Line:    scalarLine(source, node, offset+match[0], node.Value[offset+match[0]:offset+match[1]]),
+			})
+		}
+	}
+	return refs
+}
+
+// submatch returns group n of a match found in s, or "" if it did not
+// take part.
+func submatch(s string, match []int, n int) string {
+	if match[2*n] < 0 {
+		return ""
+	}
+	return s[match[2*n]:match[2*n+1]]
+}
+
+// scalarLine returns the line of source that text, found at offset in the
+// value of node, is written on. Folded and plain scalars turn line breaks
+// into spaces, so lines cannot be counted in the value. Instead text is
+// looked up in source from the line node starts on, skipping as many
+// occurrences as the value holds before offset. node.Line is returned when
+// text is not written as is, e.g. because a quoted scalar escapes it.
+func scalarLine(source []byte, node *yaml.Node, offset int, text string) int {
+	rest := source[lineStart(source, node.Line):]
+	skip := strings.Count(node.Value[:offset], text)
+	searched := 0
+	for {
+		i := bytes.Index(rest[searched:], []byte(text))
+		if i < 0 {
+			return node.Line
+		}
+		if skip == 0 {
+			return node.Line + bytes.Count(rest[:searched+i], []byte("\n"))
+		}
+		skip--
+		searched += i + len(text)
+	}
+}
+
+// lineStart returns the offset of the first byte of line in source.
+func lineStart(source []byte, line int) int {
+	offset := 0
+	for ; line > 1; line-- {
+		i := bytes.IndexByte(source[offset:], '\n')
+		if i < 0 {
+			return len(source)
+		}
+		offset += i + 1
+	}
+	return offset
+}
+
+// mappingValue returns the value of key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package usage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWorkflowReferences(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Reference
+	}{
+		{
+			name: "literal block",
+			content: `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: |
+          echo start
+          deploy --token "${{ secrets.LITERAL }}"
+`,
+			want: []Reference{{Context: ContextSecrets, Name: "LITERAL", Line: 8}},
+		},
+		{
+			name: "folded block",
+			content: `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: >
+          deploy
+          --region eu
+          --token ${{ secrets.FOLDED }}
+`,
+			want: []Reference{{Context: ContextSecrets, Name: "FOLDED", Line: 9}},
+		},
+		{
+			name: "plain multi-line scalar",
+			content: `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: deploy
+          --region ${{ vars.REGION }}
+          --token ${{ secrets.PLAIN }}
+`,
+			want: []Reference{
+				{Context: ContextVars, Name: "REGION", Line: 7},
+				{Context: ContextSecrets, Name: "PLAIN", Line: 8},
+			},
+		},
+		{
+			name: "repeated reference",
+			content: `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: >
+          login ${{ secrets.TOKEN }}
+          push ${{ secrets.TOKEN }}
+`,
+			want: []Reference{
+				{Context: ContextSecrets, Name: "TOKEN", Line: 7},
+				{Context: ContextSecrets, Name: "TOKEN", Line: 8},
+			},
+		},
+		{
+			name: "bare if expression",
+			content: `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    if: github.event_name == 'push' &&
+      secrets.IF_SECRET != ''
+    steps:
+      - run: make
+`,
+			want: []Reference{{Context: ContextSecrets, Name: "IF_SECRET", Line: 6}},
+		},
+		{
+			name: "index syntax",
+			content: `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    env:
+      A: ${{ secrets['INDEXED'] }}
+      B: "${{ secrets[\"QUOTED\"] }}"
+`,
+			want: []Reference{
+				{Context: ContextSecrets, Name: "INDEXED", Line: 6},
+				{Context: ContextSecrets, Name: "QUOTED", Line: 7},
+			},
+		},
+		{
+			name: "job environment",
+			content: `on: push
+jobs:
+  deploy:
+    runs-on: ubuntu-latest
+    environment: production
+    steps:
+      - run: deploy ${{ secrets.PROD }}
+`,
+			want: []Reference{{Context: ContextSecrets, Name: "PROD", Line: 7, Environment: "production"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow, err := ParseWorkflow(".github/workflows/ci.yml", []byte(tt.content))
+			if err != nil {
+				t.Fatalf("ParseWorkflow() error = %v", err)
+			}
+			for i := range tt.want {
+				tt.want[i].Path = ".github/workflows/ci.yml"
+			}
+			if !reflect.DeepEqual(workflow.References, tt.want) {
+				t.Errorf("ParseWorkflow() references = %+v, want %+v", workflow.References, tt.want)
+			}
+		})
+	}
+}
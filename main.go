@@ -1,15 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/katiem0/gh-export-secrets/cmd"
 )
 
 func main() {
+	// Interrupting a run cancels collection, so report files are cleaned up
+	// rather than left behind
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
 	// Instantiate and execute root command
-	cmd := cmd.NewCmd()
-	if err := cmd.Execute(); err != nil {
+	rootCmd := cmd.NewCmd()
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		// A partial report was still written, so distinguish it from a failed run
+		if errors.Is(err, cmd.ErrPartialReport) {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,85 @@
+package inventory
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// App is a GitHub feature whose secrets or variables can be collected.
+type App string
+
+const (
+	AppActions      App = "actions"
+	AppCodespaces   App = "codespaces"
+	AppDependabot   App = "dependabot"
+	AppEnvironments App = "environments"
+	AppVariables    App = "variables"
+)
+
+// AllApps returns every app that can be collected, including those added by
+// RegisterSource. The name "all" selects every one of them.
+func AllApps() []App {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	return slices.Clone(allApps)
+}
+
+// AppSet is the set of apps a Collector gathers secrets for.
+type AppSet map[App]bool
+
+// NewAppSet returns a set of the given apps.
+func NewAppSet(apps ...App) AppSet {
+	set := AppSet{}
+	for _, app := range apps {
+		set[app] = true
+	}
+	return set
+}
+
+// ParseAppSet builds a set from app names such as "actions" or "all".
+// Unknown names are an error, so typos are not mistaken for an app without
+// secrets.
+func ParseAppSet(names ...string) (AppSet, error) {
+	set := AppSet{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "all":
+			for _, app := range AllApps() {
+				set[app] = true
+			}
+		case slices.Contains(AllApps(), App(name)):
+			set[App(name)] = true
+		default:
+			return nil, fmt.Errorf("unknown app %q, expected one of: %s", name, strings.Join(AppNames(), ", "))
+		}
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("no apps selected, expected one of: %s", strings.Join(AppNames(), ", "))
+	}
+	return set, nil
+}
+
+// AppNames lists the names accepted by ParseAppSet, starting with "all".
+func AppNames() []string {
+	names := []string{"all"}
+	for _, app := range AllApps() {
+		names = append(names, string(app))
+	}
+	return names
+}
+
+// title returns the name of app as reported when a request fails, such as
+// "Environments".
+func (a App) title() string {
+	if a == "" {
+		return ""
+	}
+	return strings.ToUpper(string(a[:1])) + string(a[1:])
+}
+
+// Has reports whether app is in the set.
+func (s AppSet) Has(app App) bool {
+	return s[app]
+}
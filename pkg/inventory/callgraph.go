@@ -0,0 +1,132 @@
+package inventory
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+	"github.com/katiem0/gh-export-secrets/internal/usage"
+)
+
+// SecretFlow is a secret passed by a workflow to a reusable workflow.
+type SecretFlow = data.SecretFlow
+
+// callGraph links the workflows of the scanned repositories to the reusable
+// workflows they call. Called workflows are read from the default branch of
+// their repository, whatever ref the caller pins.
+type callGraph struct {
+	owner string
+	// repos maps lower-cased repository names to their scanned name
+	repos     map[string]string
+	workflows map[string][]*usage.Workflow
+}
+
+func newCallGraph(owner string, workflows map[string][]*usage.Workflow) *callGraph {
+	graph := &callGraph{
+		owner:     owner,
+		repos:     map[string]string{},
+		workflows: workflows,
+	}
+	for repo := range workflows {
+		graph.repos[strings.ToLower(repo)] = repo
+	}
+	return graph
+}
+
+// callee returns the repository a call is made to, as reported in flows,
+// and the workflow called if it was scanned. Repositories outside the owner
+// are reported as owner/repo.
+func (g *callGraph) callee(caller string, call usage.Call) (string, *usage.Workflow) {
+	repo := caller
+	if call.Repository != "" {
+		if !strings.EqualFold(call.Owner, g.owner) {
+			return call.Owner + "/" + call.Repository, nil
+		}
+		repo = call.Repository
+		if scanned, ok := g.repos[strings.ToLower(repo)]; ok {
+			repo = scanned
+		}
+	}
+	for _, workflow := range g.workflows[repo] {
+		if workflow.Path == call.Workflow {
+			return repo, workflow
+		}
+	}
+	return repo, nil
+}
+
+type flowNode struct {
+	repository string
+	path       string
+	name       string
+}
+
+// flows returns every hop a secret that repository can read takes into
+// reusable workflows, following calls made by called workflows in turn.
+// secrets: inherit passes the secret under its own name, an explicit
+// secrets: map under the name of each entry whose value references it.
+func (g *callGraph) flows(repository string, name string) []SecretFlow {
+	var flows []SecretFlow
+	visited := map[flowNode]bool{}
+
+	var walk func(repo string, workflow *usage.Workflow, passedName string)
+	walk = func(repo string, workflow *usage.Workflow, passedName string) {
+		node := flowNode{repo, workflow.Path, strings.ToUpper(passedName)}
+		if visited[node] {
+			return
+		}
+		visited[node] = true
+
+		for _, call := range workflow.Calls {
+			var passedAs []string
+			if call.Inherit {
+				passedAs = append(passedAs, passedName)
+			}
+			for _, passed := range call.Secrets {
+				if slices.ContainsFunc(passed.From, func(from string) bool { return strings.EqualFold(from, passedName) }) {
+					passedAs = append(passedAs, passed.Name)
+				}
+			}
+
+			calleeRepo, callee := g.callee(repo, call)
+			for _, calleeName := range passedAs {
+				flows = append(flows, SecretFlow{
+					RepositoryName:   repository,
+					SecretName:       name,
+					CallerRepository: repo,
+					CallerPath:       call.Path,
+					Line:             call.Line,
+					CalleeRepository: calleeRepo,
+					CalleeWorkflow:   call.Workflow,
+					CalleeRef:        call.Ref,
+					PassedAs:         calleeName,
+					Inherited:        call.Inherit,
+				})
+				if callee != nil {
+					walk(calleeRepo, callee, calleeName)
+				}
+			}
+		}
+	}
+
+	// Workflows that only run when called receive their caller's secrets
+	for _, workflow := range g.workflows[repository] {
+		if !workflow.CalledOnly() {
+			walk(repository, workflow, name)
+		}
+	}
+	return flows
+}
+
+// consumers returns the other repositories a secret that repository can
+// read is passed to.
+func (g *callGraph) consumers(repository string, name string) []string {
+	consumers := []string{}
+	for _, flow := range g.flows(repository, name) {
+		if flow.CalleeRepository != repository && !slices.Contains(consumers, flow.CalleeRepository) {
+			consumers = append(consumers, flow.CalleeRepository)
+		}
+	}
+	slices.Sort(consumers)
+	return consumers
+}
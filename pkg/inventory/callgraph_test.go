@@ -0,0 +1,143 @@
+package inventory_test
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/katiem0/gh-export-secrets/internal/fakegithub"
+	"github.com/katiem0/gh-export-secrets/pkg/inventory"
+)
+
+// callingWorkflow returns a workflow triggered by on whose only job calls
+// uses, passing secrets, with the uses: key on line 4.
+func callingWorkflow(on string, uses string, secrets string) string {
+	return "on: " + on + "\njobs:\n  call:\n    uses: " + uses + "\n    secrets:" + secrets + "\n"
+}
+
+const calledWorkflow = "on: workflow_call\njobs:\n  run:\n    runs-on: ubuntu-latest\n    steps:\n      - run: deploy\n"
+
+func TestCollectSecretFlows(t *testing.T) {
+	tests := []struct {
+		name string
+		// files of each repository; only app has the DEPLOY_KEY secret
+		files         map[string]map[string]string
+		wantConsumers []string
+		wantFlows     []inventory.SecretFlow
+	}{
+		{
+			name: "inherit",
+			files: map[string]map[string]string{
+				"app":    {".github/workflows/ci.yml": callingWorkflow("push", "acme/shared/.github/workflows/deploy.yml@main", " inherit")},
+				"shared": {".github/workflows/deploy.yml": calledWorkflow},
+			},
+			wantConsumers: []string{"shared"},
+			wantFlows: []inventory.SecretFlow{
+				{RepositoryName: "app", SecretName: "DEPLOY_KEY", CallerRepository: "app", CallerPath: ".github/workflows/ci.yml", Line: 4, CalleeRepository: "shared", CalleeWorkflow: ".github/workflows/deploy.yml", CalleeRef: "main", PassedAs: "DEPLOY_KEY", Inherited: true},
+			},
+		},
+		{
+			name: "explicit map renames",
+			files: map[string]map[string]string{
+				"app":    {".github/workflows/ci.yml": callingWorkflow("push", "acme/shared/.github/workflows/deploy.yml@main", "\n      KEY: ${{ secrets.DEPLOY_KEY }}\n      OTHER: ${{ secrets.UNRELATED }}")},
+				"shared": {".github/workflows/deploy.yml": calledWorkflow},
+			},
+			wantConsumers: []string{"shared"},
+			wantFlows: []inventory.SecretFlow{
+				{RepositoryName: "app", SecretName: "DEPLOY_KEY", CallerRepository: "app", CallerPath: ".github/workflows/ci.yml", Line: 4, CalleeRepository: "shared", CalleeWorkflow: ".github/workflows/deploy.yml", CalleeRef: "main", PassedAs: "KEY"},
+			},
+		},
+		{
+			name: "same repository is not a consumer",
+			files: map[string]map[string]string{
+				"app": {
+					".github/workflows/ci.yml":      callingWorkflow("push", "./.github/workflows/release.yml", " inherit"),
+					".github/workflows/release.yml": calledWorkflow,
+				},
+			},
+			wantFlows: []inventory.SecretFlow{
+				{RepositoryName: "app", SecretName: "DEPLOY_KEY", CallerRepository: "app", CallerPath: ".github/workflows/ci.yml", Line: 4, CalleeRepository: "app", CalleeWorkflow: ".github/workflows/release.yml", PassedAs: "DEPLOY_KEY", Inherited: true},
+			},
+		},
+		{
+			name: "nested hop",
+			files: map[string]map[string]string{
+				"app":    {".github/workflows/ci.yml": callingWorkflow("push", "acme/shared/.github/workflows/deploy.yml@main", "\n      KEY: ${{ secrets.DEPLOY_KEY }}")},
+				"shared": {".github/workflows/deploy.yml": callingWorkflow("workflow_call", "ACME/infra/.github/workflows/apply.yml@v2", "\n      INFRA_KEY: ${{ secrets.KEY }}")},
+				"infra":  {".github/workflows/apply.yml": calledWorkflow},
+			},
+			wantConsumers: []string{"infra", "shared"},
+			wantFlows: []inventory.SecretFlow{
+				{RepositoryName: "app", SecretName: "DEPLOY_KEY", CallerRepository: "app", CallerPath: ".github/workflows/ci.yml", Line: 4, CalleeRepository: "shared", CalleeWorkflow: ".github/workflows/deploy.yml", CalleeRef: "main", PassedAs: "KEY"},
+				{RepositoryName: "app", SecretName: "DEPLOY_KEY", CallerRepository: "shared", CallerPath: ".github/workflows/deploy.yml", Line: 4, CalleeRepository: "infra", CalleeWorkflow: ".github/workflows/apply.yml", CalleeRef: "v2", PassedAs: "INFRA_KEY"},
+			},
+		},
+		{
+			name: "cycle",
+			files: map[string]map[string]string{
+				"app":    {".github/workflows/ci.yml": callingWorkflow("push", "acme/shared/.github/workflows/deploy.yml@main", " inherit")},
+				"shared": {".github/workflows/deploy.yml": callingWorkflow("workflow_call", "acme/infra/.github/workflows/apply.yml@main", " inherit")},
+				"infra":  {".github/workflows/apply.yml": callingWorkflow("workflow_call", "acme/shared/.github/workflows/deploy.yml@main", " inherit")},
+			},
+			wantConsumers: []string{"infra", "shared"},
+			wantFlows: []inventory.SecretFlow{
+				{RepositoryName: "app", SecretName: "DEPLOY_KEY", CallerRepository: "app", CallerPath: ".github/workflows/ci.yml", Line: 4, CalleeRepository: "shared", CalleeWorkflow: ".github/workflows/deploy.yml", CalleeRef: "main", PassedAs: "DEPLOY_KEY", Inherited: true},
+				{RepositoryName: "app", SecretName: "DEPLOY_KEY", CallerRepository: "shared", CallerPath: ".github/workflows/deploy.yml", Line: 4, CalleeRepository: "infra", CalleeWorkflow: ".github/workflows/apply.yml", CalleeRef: "main", PassedAs: "DEPLOY_KEY", Inherited: true},
+				{RepositoryName: "app", SecretName: "DEPLOY_KEY", CallerRepository: "infra", CallerPath: ".github/workflows/apply.yml", Line: 4, CalleeRepository: "shared", CalleeWorkflow: ".github/workflows/deploy.yml", CalleeRef: "main", PassedAs: "DEPLOY_KEY", Inherited: true},
+			},
+		},
+		{
+			name: "other organization",
+			files: map[string]map[string]string{
+				"app": {".github/workflows/ci.yml": callingWorkflow("push", "other-org/tools/.github/workflows/scan.yml@v1", "\n      SCAN_TOKEN: ${{ secrets.DEPLOY_KEY }}")},
+			},
+			wantConsumers: []string{"other-org/tools"},
+			wantFlows: []inventory.SecretFlow{
+				{RepositoryName: "app", SecretName: "DEPLOY_KEY", CallerRepository: "app", CallerPath: ".github/workflows/ci.yml", Line: 4, CalleeRepository: "other-org/tools", CalleeWorkflow: ".github/workflows/scan.yml", CalleeRef: "v1", PassedAs: "SCAN_TOKEN"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org := &fakegithub.Organization{Login: "acme"}
+			for _, name := range []string{"app", "infra", "shared"} {
+				repo := fakegithub.Repository{ID: len(org.Repositories) + 1, Name: name, Visibility: "PRIVATE", Files: tt.files[name]}
+				if name == "app" {
+					repo.ActionsSecrets = []fakegithub.Secret{{Name: "DEPLOY_KEY"}}
+				}
+				org.Repositories = append(org.Repositories, repo)
+			}
+			server := fakegithub.NewServer(org)
+			defer server.Close()
+
+			g, err := server.NewAPIGetter()
+			if err != nil {
+				t.Fatal(err)
+			}
+			collector := inventory.NewCollector("acme", nil, inventory.NewAppSet(inventory.AppActions), g)
+			collector.ScanUsage = true
+			var flows []inventory.SecretFlow
+			collector.OnSecretFlow = func(flow inventory.SecretFlow) error {
+				flows = append(flows, flow)
+				return nil
+			}
+
+			var consumers []string
+			err = collector.Collect(context.Background(), func(export inventory.SecretExport) error {
+				consumers = export.IndirectConsumers
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			if !slices.Equal(consumers, tt.wantConsumers) {
+				t.Errorf("Collect() IndirectConsumers = %q, want %q", consumers, tt.wantConsumers)
+			}
+			if !reflect.DeepEqual(flows, tt.wantFlows) {
+				t.Errorf("Collect() secret flows:\n got: %+v\nwant: %+v", flows, tt.wantFlows)
+			}
+		})
+	}
+}
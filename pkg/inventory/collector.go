@@ -0,0 +1,531 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+	"go.uber.org/zap"
+)
+
+// errStopped is returned from emit when an iterator's consumer stops early.
+var errStopped = errors.New("iteration stopped")
+
+// errNoContentGetter is returned when usage is scanned with a Getter that
+// cannot read repository files.
+var errNoContentGetter = errors.New("scanning usage needs a Getter that implements ContentGetter")
+
+// Collector gathers the secrets and variables of an organization, or of a
+// subset of its repositories, for a set of apps.
+type Collector struct {
+	Getter Getter
+	Owner  string
+	// Repos limits collection to the named repositories. When empty, every
+	// repository in the organization is collected along with the
+	// organization level secrets.
+	Repos []string
+	Apps  AppSet
+
+	// Concurrency is the number of repositories collected in parallel.
+	Concurrency int
+	// IncludeValues keeps the value of Actions variables in exports.
+	IncludeValues bool
+	// ScanUsage fetches the workflows of every repository and sets
+	// Referenced, ReferencedBy and IndirectConsumers on each export. It
+	// costs one request per repository plus one per workflow file, and
+	// Getter must also implement ContentGetter.
+	ScanUsage bool
+	// OnFinding is called with each finding once collection completes when
+	// ScanUsage is set, ordered by repository, file and line, with
+	// organization findings first. Returning an error stops collection.
+	OnFinding func(Finding) error
+	// OnSecretFlow is called once collection completes when ScanUsage is
+	// set, with every hop an Actions secret takes into a reusable workflow,
+	// ordered by repository and secret. Returning an error stops collection.
+	OnSecretFlow func(SecretFlow) error
+	// UpdatedBefore and UpdatedSince, when not zero, only keep secrets last
+	// updated before or on and after the given time.
+	UpdatedBefore time.Time
+	UpdatedSince  time.Time
+
+	// OnError is called with every error returned by Getter, along with the
+	// repository (empty for organization requests) and the app being
+	// collected. Returning nil skips the failed resource and returning an
+	// error stops collection. When nil, missing resources such as a
+	// repository without Codespaces enabled are skipped and any other error
+	// stops collection.
+	OnError func(repository string, app string, err error) error
+}
+
+// NewCollector returns a Collector for owner that uses g to query GitHub.
+func NewCollector(owner string, repos []string, apps AppSet, g Getter) *Collector {
+	return &Collector{
+		Getter:      g,
+		Owner:       owner,
+		Repos:       repos,
+		Apps:        apps,
+		Concurrency: 1,
+	}
+}
+
+// Collect passes one export per secret and repository pairing to emit.
+// Organization level secrets are emitted first, followed by repository and
+// environment level secrets in repository name order. Collection stops at
+// the first error returned by emit or when ctx is cancelled.
+func (c *Collector) Collect(ctx context.Context, emit func(SecretExport) error) error {
+	return c.collect(ctx, emit)
+}
+
+// Exports returns an iterator over the exports produced by Collect. A
+// collection error is yielded once, as the last pair, with a zero export.
+func (c *Collector) Exports(ctx context.Context) iter.Seq2[SecretExport, error] {
+	return func(yield func(SecretExport, error) bool) {
+		err := c.collect(ctx, func(export SecretExport) error {
+			if !yield(export, nil) {
+				return errStopped
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopped) {
+			yield(SecretExport{}, err)
+		}
+	}
+}
+
+// inUpdateWindow reports whether a secret last updated at updatedAt falls
+// within UpdatedBefore and UpdatedSince.
+func (c *Collector) inUpdateWindow(updatedAt time.Time) bool {
+	if !c.UpdatedBefore.IsZero() && !updatedAt.Before(c.UpdatedBefore) {
+		return false
+	}
+	if !c.UpdatedSince.IsZero() && updatedAt.Before(c.UpdatedSince) {
+		return false
+	}
+	return true
+}
+
+// skip passes a getter error to OnError, or applies the default policy.
+// A repository that was asked for by name and does not exist is not a
+// missing optional resource, so it is never skipped by default.
+func (c *Collector) skip(repository string, app string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if c.OnError != nil {
+		return c.OnError(repository, app, err)
+	}
+	if errors.Is(err, ErrNotFound) && app != "Repository" {
+		zap.S().Warnf("Skipping missing resource: %v", err)
+		return nil
+	}
+	return err
+}
+
+// collect gathers secrets and variables for the selected apps and passes
+// one export per secret and repository pairing to emit.
+func (c *Collector) collect(ctx context.Context, emit func(data.SecretExport) error) error {
+	var reposCursor *string
+	var allRepos []data.RepoInfo
+	var index *usageIndex
+	var reach *reachability
+	if c.ScanUsage {
+		reach = newReachability()
+	}
+
+	if _, ok := c.Getter.(ContentGetter); c.ScanUsage && !ok {
+		return errNoContentGetter
+	}
+
+	emitExport := emit
+	emit = func(export data.SecretExport) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !c.IncludeValues {
+			export.VariableValue = ""
+		}
+		if c.ScanUsage {
+			annotateUsage(&export, index)
+		}
+		return emitExport(export)
+	}
+
+	if len(c.Repos) > 0 {
+		zap.S().Infof("Processing repos: %s", c.Repos)
+
+		for _, repo := range c.Repos {
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			zap.S().Debugf("Processing %s/%s", c.Owner, repo)
+
+			repoQuery, err := c.Getter.GetRepo(ctx, c.Owner, repo)
+			if err != nil {
+				if err = c.skip(repo, "Repository", err); err != nil {
+					return err
+				}
+				continue
+			}
+			allRepos = append(allRepos, repoQuery.Repository)
+		}
+
+	} else {
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			zap.S().Debugf("Processing list of repositories for %s", c.Owner)
+			reposQuery, err := c.Getter.GetReposList(ctx, c.Owner, reposCursor)
+
+			if err != nil {
+				return err
+			}
+
+			allRepos = append(allRepos, reposQuery.Organization.Repositories.Nodes...)
+
+			reposCursor = &reposQuery.Organization.Repositories.PageInfo.EndCursor
+
+			if !reposQuery.Organization.Repositories.PageInfo.HasNextPage {
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(allRepos, func(i, j int) bool {
+		return strings.ToLower(allRepos[i].Name) < strings.ToLower(allRepos[j].Name)
+	})
+
+	if c.ScanUsage {
+		usages, err := c.scanUsage(ctx, allRepos)
+		if err != nil {
+			return err
+		}
+		index = newUsageIndex(c.Owner, usages)
+	}
+
+	// Collect organization level secrets and variables. When repositories
+	// are named they are only needed to know which secrets those can read.
+	if len(c.Repos) == 0 || c.ScanUsage {
+		orgEmit := emit
+		if len(c.Repos) > 0 {
+			orgEmit = func(data.SecretExport) error { return nil }
+		}
+		for _, source := range Sources() {
+			if !c.Apps.Has(source.App()) {
+				continue
+			}
+			if err := c.collectOrgSource(ctx, source, allRepos, reach, orgEmit); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Collect repository level Secrets
+	if err := c.collectRepos(ctx, allRepos, reach, emit); err != nil {
+		return err
+	}
+
+	if !c.ScanUsage {
+		return nil
+	}
+	var findings []Finding
+	if c.Apps.Has(AppActions) {
+		findings = append(findings, missingSecrets(allRepos, index.workflowRefs, reach, c.Apps.Has(AppEnvironments))...)
+	} else {
+		zap.S().Warnf("Skipping workflow findings and secret flows as Actions secrets were not collected")
+	}
+	if c.Apps.Has(AppDependabot) {
+		findings = append(findings, configFindings("Dependabot", "Dependabot registry or workflow Dependabot triggers", allRepos, index.registryRefs, index.dependabotRefs, reach, len(c.Repos) == 0)...)
+	}
+	if c.Apps.Has(AppActions) && c.Apps.Has(AppDependabot) {
+		findings = append(findings, dependabotRunSecrets(allRepos, index, reach)...)
+	}
+	if c.Apps.Has(AppCodespaces) {
+		findings = append(findings, configFindings("Codespaces", "devcontainer.json", allRepos, index.devcontainerRefs, index.devcontainerRefs, reach, len(c.Repos) == 0)...)
+	}
+	if reach.incomplete() {
+		zap.S().Warnf("Skipping findings that rely on secrets that could not be listed")
+	}
+	sortFindings(findings)
+	for _, finding := range findings {
+		if c.OnFinding == nil {
+			break
+		}
+		if err := c.OnFinding(finding); err != nil {
+			return err
+		}
+	}
+	for _, repo := range allRepos {
+		if c.OnSecretFlow == nil || !c.Apps.Has(AppActions) {
+			break
+		}
+		for _, name := range reach.names("Actions", repo.Name) {
+			for _, flow := range index.graph.flows(repo.Name, name) {
+				if err := c.OnSecretFlow(flow); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// collectOrgSource emits the organization level entries of source, once
+// for every repository each entry is exposed to.
+func (c *Collector) collectOrgSource(ctx context.Context, source SecretSource, allRepos []data.RepoInfo, reach *reachability, emit func(data.SecretExport) error) error {
+	secretType := source.SecretType()
+
+	orgItems, err := source.OrgItems(ctx, c.Getter, c.Owner)
+	if err != nil {
+		if err = c.skip("", secretType, err); err != nil {
+			return err
+		}
+		reach.skip(secretType, "", "")
+	}
+
+	if len(orgItems) == 0 {
+		zap.S().Debugf("No org level %s secrets for %s", secretType, c.Owner)
+	} else {
+		zap.S().Debugf("Gathering %s secrets for %s", secretType, c.Owner)
+	}
+	for _, orgItem := range orgItems {
+		inWindow := c.inUpdateWindow(orgItem.UpdatedAt)
+		if !inWindow && reach == nil {
+			continue
+		}
+		var scopedRepos []data.ScopedRepository
+		if orgItem.Visibility == "selected" {
+			zap.S().Debugf("Gathering %s secret %s for %s that is scoped to specific repositories", secretType, orgItem.Name, c.Owner)
+			scopedRepos, err = source.ScopedRepositories(ctx, c.Getter, c.Owner, orgItem.Name)
+			if err != nil {
+				if err = c.skip("", secretType, err); err != nil {
+					return err
+				}
+				// The repositories it is exposed to are unknown, and the
+				// failure is already reported, so write no rows for it
+				reach.skip(secretType, "", "")
+				continue
+			}
+		} else {
+			zap.S().Debugf("Gathering %s secret %s for %s that is accessible to %s repositories", secretType, orgItem.Name, c.Owner, orgItem.Visibility)
+		}
+
+		exposedRepos := data.ResolveExposure(orgItem.Visibility, allRepos, scopedRepos)
+		exposedNames := make([]string, 0, len(exposedRepos))
+		for _, exposedRepo := range exposedRepos {
+			exposedNames = append(exposedNames, exposedRepo.Name)
+		}
+		reach.addOrg(secretType, orgItem.Name, exposedNames)
+		if !inWindow {
+			continue
+		}
+		if len(exposedRepos) == 0 {
+			// Still report secrets that no repository can currently read
+			err = emit(data.SecretExport{
+				SecretLevel:     "Organization",
+				SecretType:      secretType,
+				SecretName:      orgItem.Name,
+				SecretAccess:    orgItem.Visibility,
+				SecretCreatedAt: orgItem.CreatedAt,
+				SecretUpdatedAt: orgItem.UpdatedAt,
+				VariableValue:   orgItem.Value,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, exposedRepo := range exposedRepos {
+			err = emit(data.SecretExport{
+				SecretLevel:          "Organization",
+				SecretType:           secretType,
+				SecretName:           orgItem.Name,
+				SecretAccess:         orgItem.Visibility,
+				RepositoryName:       exposedRepo.Name,
+				RepositoryID:         exposedRepo.ID,
+				RepositoryVisibility: exposedRepo.Visibility,
+				SecretCreatedAt:      orgItem.CreatedAt,
+				SecretUpdatedAt:      orgItem.UpdatedAt,
+				VariableValue:        orgItem.Value,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// repoResult holds the exports gathered for a single repository.
+type repoResult struct {
+	exports []data.SecretExport
+	err     error
+}
+
+// collectRepos fans the per repository calls out to a bounded pool of
+// workers. Results are emitted in the order of allRepos as soon as each
+// repository and all of those before it have completed, so output is
+// deterministic regardless of completion order. On an early return the
+// workers' requests are cancelled and they are waited for, so none is left
+// calling Getter once it returns.
+func (c *Collector) collectRepos(ctx context.Context, allRepos []data.RepoInfo, reach *reachability, emit func(data.SecretExport) error) error {
+	concurrency := max(c.Concurrency, 1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	results := make([]chan repoResult, len(allRepos))
+	for i := range results {
+		results[i] = make(chan repoResult, 1)
+	}
+
+	jobs := make(chan int)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for i := range allRepos {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				var result repoResult
+				if result.err = ctx.Err(); result.err == nil {
+					result.err = c.collectRepoSecrets(ctx, allRepos[i], reach, func(export data.SecretExport) error {
+						result.exports = append(result.exports, export)
+						return nil
+					})
+				}
+				results[i] <- result
+			}
+		}()
+	}
+
+	for i := range allRepos {
+		result := <-results[i]
+		if result.err != nil {
+			return result.err
+		}
+		for _, export := range result.exports {
+			if err := emit(export); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// collectRepoSecrets gathers the repository and environment level secrets
+// and variables of every selected source for a single repository.
+func (c *Collector) collectRepoSecrets(ctx context.Context, singleRepo data.RepoInfo, reach *reachability, emit func(data.SecretExport) error) error {
+	// Collect repository level secrets and variables
+	for _, source := range Sources() {
+		if !c.Apps.Has(source.App()) {
+			continue
+		}
+		repoItems, err := source.RepoItems(ctx, c.Getter, c.Owner, singleRepo.Name)
+		if err != nil {
+			if err = c.skip(singleRepo.Name, source.SecretType(), err); err != nil {
+				return err
+			}
+			reach.skip(source.SecretType(), singleRepo.Name, "")
+		}
+		for _, repoItem := range repoItems {
+			reach.addRepo(source.SecretType(), singleRepo.Name, repoItem.Name)
+			if !c.inUpdateWindow(repoItem.UpdatedAt) {
+				continue
+			}
+			err = emit(data.SecretExport{
+				SecretLevel:          "Repository",
+				SecretType:           source.SecretType(),
+				SecretName:           repoItem.Name,
+				SecretAccess:         "RepoOnly",
+				RepositoryName:       singleRepo.Name,
+				RepositoryID:         singleRepo.DatabaseId,
+				RepositoryVisibility: data.NormalizeVisibility(singleRepo.Visibility),
+				SecretCreatedAt:      repoItem.CreatedAt,
+				SecretUpdatedAt:      repoItem.UpdatedAt,
+				VariableValue:        repoItem.Value,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// Collect environment level secrets and variables
+	var envSources []EnvironmentSource
+	for _, source := range Sources() {
+		if envSource, ok := source.(EnvironmentSource); ok && c.Apps.Has(envSource.EnvironmentApp()) {
+			envSources = append(envSources, envSource)
+		}
+	}
+	if len(envSources) > 0 {
+		repoEnvList, err := c.Getter.GetRepoEnvironments(ctx, c.Owner, singleRepo.Name)
+		if err != nil {
+			if err = c.skip(singleRepo.Name, "Environments", err); err != nil {
+				return err
+			}
+			for _, envSource := range envSources {
+				reach.skip(envSource.SecretType(), singleRepo.Name, allEnvironments)
+			}
+		}
+		if len(repoEnvList) == 0 {
+			zap.S().Debugf("No environments for %s/%s", c.Owner, singleRepo.Name)
+		}
+		for _, repoEnv := range repoEnvList {
+			for _, envSource := range envSources {
+				secretType := envSource.SecretType()
+				zap.S().Debugf("Gathering environment %s for %s/%s environment %s", secretType, c.Owner, singleRepo.Name, repoEnv.Name)
+				envItems, err := envSource.EnvironmentItems(ctx, c.Getter, c.Owner, singleRepo.Name, repoEnv.Name)
+				if err != nil {
+					if err = c.skip(singleRepo.Name, envSource.EnvironmentApp().title(), err); err != nil {
+						return err
+					}
+					reach.skip(secretType, singleRepo.Name, repoEnv.Name)
+				}
+				for _, envItem := range envItems {
+					reach.add(secretType, singleRepo.Name, repoEnv.Name, envItem.Name)
+					if !c.inUpdateWindow(envItem.UpdatedAt) {
+						continue
+					}
+					err = emit(data.SecretExport{
+						SecretLevel:          "Environment",
+						SecretType:           secretType,
+						SecretName:           envItem.Name,
+						SecretAccess:         repoEnv.Name,
+						RepositoryName:       singleRepo.Name,
+						RepositoryID:         singleRepo.DatabaseId,
+						RepositoryVisibility: data.NormalizeVisibility(singleRepo.Visibility),
+						SecretCreatedAt:      envItem.CreatedAt,
+						SecretUpdatedAt:      envItem.UpdatedAt,
+						VariableValue:        envItem.Value,
+					})
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
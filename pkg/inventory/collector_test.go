@@ -0,0 +1,204 @@
+package inventory_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-export-secrets/internal/fakegithub"
+	"github.com/katiem0/gh-export-secrets/pkg/inventory"
+)
+
+func TestCollectCancelsRateLimitWait(t *testing.T) {
+	server := fakegithub.NewServer(&fakegithub.Organization{
+		Login:        "acme",
+		Repositories: []fakegithub.Repository{{ID: 1, Name: "api", Visibility: "PRIVATE"}},
+	})
+	defer server.Close()
+	server.RateLimitReset = time.Now().Add(time.Hour)
+	server.RateLimit(1)
+
+	g, err := server.NewAPIGetter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := inventory.NewCollector("acme", nil, inventory.NewAppSet(inventory.AppActions), g)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = collector.Collect(ctx, func(inventory.SecretExport) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Collect() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Collect() returned after %s, want it to stop waiting when ctx is done", elapsed)
+	}
+}
+
+func TestCollectSkipsFindingsForFailedListings(t *testing.T) {
+	workflow := map[string]string{
+		".github/workflows/deploy.yml": "on: push\njobs:\n  deploy:\n    runs-on: ubuntu-latest\n    steps:\n      - run: deploy\n        env:\n          KEY: ${{ secrets.DEPLOY_KEY }}\n",
+	}
+	server := fakegithub.NewServer(&fakegithub.Organization{
+		Login: "acme",
+		Repositories: []fakegithub.Repository{
+			{ID: 1, Name: "api", Visibility: "PRIVATE", Files: workflow},
+			{ID: 2, Name: "web", Visibility: "PRIVATE", Files: workflow},
+		},
+	})
+	defer server.Close()
+	server.Forbid("/repos/acme/api/actions/secrets")
+
+	g, err := server.NewAPIGetter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := inventory.NewCollector("acme", nil, inventory.NewAppSet(inventory.AppActions), g)
+	collector.ScanUsage = true
+	collector.OnError = func(string, string, error) error { return nil }
+	var findings []inventory.Finding
+	collector.OnFinding = func(finding inventory.Finding) error {
+		findings = append(findings, finding)
+		return nil
+	}
+
+	if err = collector.Collect(context.Background(), func(inventory.SecretExport) error { return nil }); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(findings) != 1 || findings[0].RepositoryName != "web" || findings[0].SecretName != "DEPLOY_KEY" {
+		t.Errorf("Collect() findings = %+v, want a single DEPLOY_KEY finding for web", findings)
+	}
+}
+
+func TestCollectDependabotReferencesAgree(t *testing.T) {
+	server := fakegithub.NewServer(&fakegithub.Organization{
+		Login: "acme",
+		Repositories: []fakegithub.Repository{{
+			ID: 1, Name: "api", Visibility: "PRIVATE",
+			DependabotSecrets: []fakegithub.Secret{{Name: "NPM_TOKEN"}, {Name: "UNUSED"}},
+			Files: map[string]string{
+				".github/dependabot.yml":       "version: 2\nupdates:\n  - package-ecosystem: npm\n    directory: /\n    schedule:\n      interval: daily\n",
+				".github/workflows/test.yml":   "on: pull_request\njobs:\n  test:\n    runs-on: ubuntu-latest\n    steps:\n      - run: npm ci\n        env:\n          NPM_TOKEN: ${{ secrets.NPM_TOKEN }}\n",
+				".github/workflows/deploy.yml": "on: workflow_dispatch\njobs:\n  deploy:\n    runs-on: ubuntu-latest\n    steps:\n      - run: deploy\n        env:\n          KEY: ${{ secrets.UNUSED }}\n",
+			},
+		}},
+	})
+	defer server.Close()
+
+	g, err := server.NewAPIGetter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := inventory.NewCollector("acme", nil, inventory.NewAppSet(inventory.AppDependabot), g)
+	collector.ScanUsage = true
+	var unused []string
+	collector.OnFinding = func(finding inventory.Finding) error {
+		if finding.Kind == inventory.FindingUnusedSecret {
+			unused = append(unused, finding.SecretName)
+		}
+		return nil
+	}
+
+	referenced := map[string]bool{}
+	err = collector.Collect(context.Background(), func(export inventory.SecretExport) error {
+		if export.SecretLevel == "Repository" {
+			referenced[export.SecretName] = *export.Referenced
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if !referenced["NPM_TOKEN"] || referenced["UNUSED"] {
+		t.Errorf("Collect() Referenced = %v, want only NPM_TOKEN referenced", referenced)
+	}
+	if len(unused) != 1 || unused[0] != "UNUSED" {
+		t.Errorf("Collect() UnusedSecret findings = %v, want [UNUSED]", unused)
+	}
+}
+
+func TestCollectDependabotRunWithSecurityUpdates(t *testing.T) {
+	workflow := map[string]string{
+		".github/workflows/test.yml": "on: pull_request\njobs:\n  test:\n    runs-on: ubuntu-latest\n    steps:\n      - run: npm ci\n        env:\n          NPM_TOKEN: ${{ secrets.NPM_TOKEN }}\n",
+	}
+	server := fakegithub.NewServer(&fakegithub.Organization{
+		Login: "acme",
+		Repositories: []fakegithub.Repository{
+			{ID: 1, Name: "api", Visibility: "PRIVATE", ActionsSecrets: []fakegithub.Secret{{Name: "NPM_TOKEN"}}, Files: workflow, SecurityUpdates: true},
+			{ID: 2, Name: "web", Visibility: "PRIVATE", ActionsSecrets: []fakegithub.Secret{{Name: "NPM_TOKEN"}}, Files: workflow},
+		},
+	})
+	defer server.Close()
+
+	g, err := server.NewAPIGetter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := inventory.NewCollector("acme", nil, inventory.NewAppSet(inventory.AppActions, inventory.AppDependabot), g)
+	collector.ScanUsage = true
+	var findings []inventory.Finding
+	collector.OnFinding = func(finding inventory.Finding) error {
+		findings = append(findings, finding)
+		return nil
+	}
+
+	if err = collector.Collect(context.Background(), func(inventory.SecretExport) error { return nil }); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(findings) != 1 || findings[0].Kind != inventory.FindingDependabotRun || findings[0].RepositoryName != "api" {
+		t.Errorf("Collect() findings = %+v, want a single DependabotRunSecret finding for api", findings)
+	}
+}
+
+// slowGetter delays repository requests and counts those in flight.
+type slowGetter struct {
+	inventory.Getter
+	active atomic.Int32
+}
+
+func (g *slowGetter) GetRepoActionSecrets(ctx context.Context, owner string, repo string) ([]inventory.Secret, error) {
+	g.active.Add(1)
+	defer g.active.Add(-1)
+	select {
+	case <-ctx.Done():
+	case <-time.After(50 * time.Millisecond):
+	}
+	return g.Getter.GetRepoActionSecrets(ctx, owner, repo)
+}
+
+func TestCollectWaitsForWorkersOnEarlyReturn(t *testing.T) {
+	org := &fakegithub.Organization{Login: "acme"}
+	for i := 0; i < 20; i++ {
+		org.Repositories = append(org.Repositories, fakegithub.Repository{
+			ID: i + 1, Name: fmt.Sprintf("repo%02d", i), Visibility: "PRIVATE",
+			ActionsSecrets: []fakegithub.Secret{{Name: "TOKEN"}},
+		})
+	}
+	server := fakegithub.NewServer(org)
+	defer server.Close()
+
+	apiGetter, err := server.NewAPIGetter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &slowGetter{Getter: apiGetter}
+	collector := inventory.NewCollector("acme", nil, inventory.NewAppSet(inventory.AppActions), g)
+	collector.Concurrency = 4
+
+	for export, err := range collector.Exports(context.Background()) {
+		if err != nil {
+			t.Fatalf("Exports() error = %v", err)
+		}
+		if export.RepositoryName != "" {
+			break
+		}
+	}
+	if active := g.active.Load(); active != 0 {
+		t.Errorf("%d requests still in flight after Exports() stopped", active)
+	}
+}
@@ -0,0 +1,406 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+	"github.com/katiem0/gh-export-secrets/internal/usage"
+)
+
+// Finding is a problem found by comparing the secrets a repository
+// references with the secrets it can read.
+type Finding = data.Finding
+
+// Finding kinds.
+const (
+	FindingMissingSecret = data.FindingMissingSecret
+	FindingUnusedSecret  = data.FindingUnusedSecret
+	FindingDependabotRun = data.FindingDependabotRun
+)
+
+// builtinSecrets are provided by GitHub to every workflow run.
+var builtinSecrets = map[string]bool{
+	"GITHUB_TOKEN": true,
+}
+
+// dependabotEvents trigger workflow runs for Dependabot's pull requests,
+// which can only read Dependabot secrets.
+var dependabotEvents = []string{"pull_request", "push"}
+
+type reachKey struct {
+	secretType string
+	repository string
+	name       string
+}
+
+type orgKey struct {
+	secretType string
+	name       string
+}
+
+// listingKey identifies a listing of the secrets of secretType of
+// repository, or of the organization when repository is empty, in
+// environment if set. allEnvironments stands for every environment of the
+// repository, when they could not be listed.
+type listingKey struct {
+	secretType  string
+	repository  string
+	environment string
+}
+
+const allEnvironments = "*"
+
+// reachability records the secrets each repository can read, regardless of
+// the update window, so that filtering the report never produces findings.
+type reachability struct {
+	mu sync.Mutex
+	// environments holds the environments a secret is defined in for a
+	// repository, with "" for repository and organization level secrets.
+	environments map[reachKey]map[string]bool
+	// orgRepos holds the repositories each organization secret is exposed to
+	orgRepos map[orgKey][]string
+	// repoNames holds repository level secrets by reachKey, as named
+	repoNames map[reachKey]string
+	// skipped holds the listings that failed and were skipped. The secrets
+	// they would have returned are unknown, so no finding relies on them.
+	skipped map[listingKey]bool
+}
+
+func newReachability() *reachability {
+	return &reachability{
+		environments: map[reachKey]map[string]bool{},
+		orgRepos:     map[orgKey][]string{},
+		repoNames:    map[reachKey]string{},
+		skipped:      map[listingKey]bool{},
+	}
+}
+
+// skip records that a listing of secrets of secretType failed and was
+// skipped. An empty repository stands for the organization listing, or for
+// the repositories an organization secret is scoped to.
+func (r *reachability) skip(secretType string, repository string, environment string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.skipped[listingKey{secretType, repository, strings.ToLower(environment)}] = true
+}
+
+// complete reports whether every listing of secrets of secretType that a
+// job in repository deploying to environment, if any, can read succeeded.
+func (r *reachability) complete(secretType string, repository string, environment string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.skipped[listingKey{secretType, "", ""}] || r.skipped[listingKey{secretType, repository, ""}] {
+		return false
+	}
+	switch {
+	case environment == "":
+		return true
+	case r.skipped[listingKey{secretType, repository, allEnvironments}]:
+		return false
+	case strings.Contains(environment, "${{"):
+		for key := range r.skipped {
+			if key.secretType == secretType && key.repository == repository {
+				return false
+			}
+		}
+		return true
+	default:
+		return !r.skipped[listingKey{secretType, repository, strings.ToLower(environment)}]
+	}
+}
+
+// incomplete reports whether any listing was skipped.
+func (r *reachability) incomplete() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.skipped) > 0
+}
+
+// addOrg records an organization secret and the repositories that can
+// read it.
+func (r *reachability) addOrg(secretType string, name string, repositories []string) {
+	if r == nil {
+		return
+	}
+	for _, repository := range repositories {
+		r.add(secretType, repository, "", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.orgRepos[orgKey{secretType, name}] = repositories
+}
+
+// addRepo records a repository level secret.
+func (r *reachability) addRepo(secretType string, repository string, name string) {
+	if r == nil {
+		return
+	}
+	r.add(secretType, repository, "", name)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.repoNames[reachKey{secretType, repository, strings.ToUpper(name)}] = name
+}
+
+// add records that repository can read a secret, in environment if set.
+func (r *reachability) add(secretType string, repository string, environment string, name string) {
+	if r == nil {
+		return
+	}
+	key := reachKey{secretType, repository, strings.ToUpper(name)}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.environments[key] == nil {
+		r.environments[key] = map[string]bool{}
+	}
+	r.environments[key][strings.ToLower(environment)] = true
+}
+
+// has reports whether a job in repository deploying to environment, if
+// any, can read a secret. A job whose environment is an expression may
+// deploy to any environment.
+func (r *reachability) has(secretType string, repository string, environment string, name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	environments := r.environments[reachKey{secretType, repository, strings.ToUpper(name)}]
+	switch {
+	case environments[""]:
+		return true
+	case environment == "":
+		return false
+	case strings.Contains(environment, "${{"):
+		return len(environments) > 0
+	default:
+		return environments[strings.ToLower(environment)]
+	}
+}
+
+// names returns the repository and organization level secrets of
+// secretType that repository can read, sorted.
+func (r *reachability) names(secretType string, repository string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var names []string
+	for key, environments := range r.environments {
+		if key.secretType == secretType && key.repository == repository && environments[""] {
+			names = append(names, key.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// repoSecrets returns the repository level secrets of secretType of
+// repository, sorted.
+func (r *reachability) repoSecrets(secretType string, repository string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var names []string
+	for key, name := range r.repoNames {
+		if key.secretType == secretType && key.repository == repository {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// orgSecrets returns the organization secrets of secretType, sorted.
+func (r *reachability) orgSecrets(secretType string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var names []string
+	for key := range r.orgRepos {
+		if key.secretType == secretType {
+			names = append(names, key.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// missingSecrets returns a finding for every workflow reference to a secret
+// that no Actions secret reachable by the repository satisfies. References
+// from jobs that deploy to an environment are only checked when environment
+// secrets were collected, and references whose secrets could not all be
+// listed are not checked. Workflows that only run when called are not
+// checked, as their secrets are passed in by callers.
+func missingSecrets(allRepos []data.RepoInfo, refs map[string][]Reference, reach *reachability, checkEnvironments bool) []Finding {
+	var findings []Finding
+	for _, repo := range allRepos {
+		for _, ref := range refs[repo.Name] {
+			if ref.Context != usage.ContextSecrets || ref.CalledOnly || builtinSecrets[strings.ToUpper(ref.Name)] {
+				continue
+			}
+			if reach.has("Actions", repo.Name, ref.Environment, ref.Name) {
+				continue
+			}
+			if ref.Environment != "" && !checkEnvironments {
+				continue
+			}
+			if !reach.complete("Actions", repo.Name, ref.Environment) {
+				continue
+			}
+
+			message := "No repository or organization secret with this name is available to the repository"
+			if ref.Environment != "" {
+				message = fmt.Sprintf("No repository, organization or %q environment secret with this name is available to the repository", ref.Environment)
+			}
+			findings = append(findings, Finding{
+				Kind:           FindingMissingSecret,
+				RepositoryName: repo.Name,
+				Path:           ref.Path,
+				Line:           ref.Line,
+				SecretType:     "Actions",
+				SecretName:     ref.Name,
+				Message:        message,
+			})
+		}
+	}
+	return findings
+}
+
+// dependabotRunSecrets returns a finding for every reference to an Actions
+// secret, in a workflow that Dependabot's pull requests trigger, that has no
+// Dependabot secret of the same name the repository can read. Repositories
+// whose Dependabot secrets could not be listed are not checked.
+func dependabotRunSecrets(allRepos []data.RepoInfo, index *usageIndex, reach *reachability) []Finding {
+	var findings []Finding
+	for _, repo := range allRepos {
+		if !reach.complete("Dependabot", repo.Name, "") {
+			continue
+		}
+		for _, workflow := range index.dependabotWorkflows[repo.Name] {
+			for _, ref := range workflow.References {
+				if ref.Context != usage.ContextSecrets || builtinSecrets[strings.ToUpper(ref.Name)] {
+					continue
+				}
+				if !reach.has("Actions", repo.Name, ref.Environment, ref.Name) || reach.has("Dependabot", repo.Name, "", ref.Name) {
+					continue
+				}
+				findings = append(findings, Finding{
+					Kind:           FindingDependabotRun,
+					RepositoryName: repo.Name,
+					Path:           ref.Path,
+					Line:           ref.Line,
+					SecretType:     "Dependabot",
+					SecretName:     ref.Name,
+					Message:        "Runs triggered by Dependabot can only read Dependabot secrets, and no Dependabot secret with this name is available to the repository",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// sortFindings orders findings by repository, file, line and secret.
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.RepositoryName != b.RepositoryName {
+			return strings.ToLower(a.RepositoryName) < strings.ToLower(b.RepositoryName)
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.SecretName < b.SecretName
+	})
+}
+
+// configFindings compares the secrets referenced by configuration files,
+// such as the registries of a Dependabot configuration, with the secrets of
+// secretType each repository can read. A reference in refs that none
+// satisfies is a MissingSecret finding, and a secret that no reference in
+// uses of any repository that can read it names is an UnusedSecret finding.
+// uses holds refs and any other files that read the secrets, and consumer
+// names them in messages. Organization secrets are only
+// reported unused when every repository that can read them was scanned, and
+// no MissingSecret finding is reported for a repository whose secrets could
+// not all be listed.
+func configFindings(secretType string, consumer string, allRepos []data.RepoInfo, refs map[string][]Reference, uses map[string][]Reference, reach *reachability, allScanned bool) []Finding {
+	var findings []Finding
+
+	referenced := func(repository string, name string) bool {
+		for _, ref := range uses[repository] {
+			if ref.Context != usage.ContextSecrets {
+				continue
+			}
+			if strings.EqualFold(ref.Name, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, repo := range allRepos {
+		for _, ref := range refs[repo.Name] {
+			if reach.has(secretType, repo.Name, "", ref.Name) || !reach.complete(secretType, repo.Name, "") {
+				continue
+			}
+			findings = append(findings, Finding{
+				Kind:           FindingMissingSecret,
+				RepositoryName: repo.Name,
+				Path:           ref.Path,
+				Line:           ref.Line,
+				SecretType:     secretType,
+				SecretName:     ref.Name,
+				Message:        fmt.Sprintf("No repository or organization %s secret with this name is available to the repository", secretType),
+			})
+		}
+		for _, name := range reach.repoSecrets(secretType, repo.Name) {
+			if referenced(repo.Name, name) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Kind:           FindingUnusedSecret,
+				RepositoryName: repo.Name,
+				SecretType:     secretType,
+				SecretName:     name,
+				Message:        fmt.Sprintf("No %s in the repository references this repository secret", consumer),
+			})
+		}
+	}
+
+	if !allScanned || !reach.complete(secretType, "", "") {
+		return findings
+	}
+	for _, name := range reach.orgSecrets(secretType) {
+		repositories := reach.orgRepos[orgKey{secretType, name}]
+		used := false
+		for _, repository := range repositories {
+			if referenced(repository, name) {
+				used = true
+				break
+			}
+		}
+		if used {
+			continue
+		}
+		findings = append(findings, Finding{
+			Kind:       FindingUnusedSecret,
+			SecretType: secretType,
+			SecretName: name,
+			Message:    fmt.Sprintf("No %s in any repository that can read this organization secret references it", consumer),
+		})
+	}
+	return findings
+}
@@ -0,0 +1,69 @@
+// Package inventory collects the Actions, Codespaces and Dependabot secrets
+// and Actions variables of a GitHub organization, along with the
+// repositories each one is exposed to. It is the library behind the
+// gh export-secrets extension.
+//
+//	g := inventory.NewAPIGetter(gqlClient, restClient)
+//	collector := inventory.NewCollector("my-org", nil, inventory.NewAppSet(inventory.AllApps()...), g)
+//	for export, err := range collector.Exports(ctx) {
+//		...
+//	}
+package inventory
+
+import (
+	"net/http"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/katiem0/gh-export-secrets/internal/data"
+)
+
+// Getter retrieves repositories, secrets and variables for an organization.
+type Getter = data.Getter
+
+// ContentGetter reads repository files. The Getter of a Collector must also
+// implement it to scan usage.
+type ContentGetter = data.ContentGetter
+
+// DependabotGetter reports whether Dependabot security updates are enabled.
+// When the Getter of a Collector implements it, usage scanning also treats
+// repositories without a Dependabot configuration as running Dependabot
+// when they are.
+type DependabotGetter = data.DependabotGetter
+
+// APIGetter implements Getter against the GitHub REST and GraphQL APIs.
+type APIGetter = data.APIGetter
+
+// Types returned by Getter.
+type (
+	SecretExport     = data.SecretExport
+	Secret           = data.Secret
+	Variable         = data.Variable
+	ScopedRepository = data.ScopedRepository
+	Environment      = data.Environment
+	ContentEntry     = data.ContentEntry
+	RepoInfo         = data.RepoInfo
+	ReposQuery       = data.ReposQuery
+	RepoQuery        = data.RepoQuery
+	APIError         = data.APIError
+)
+
+// Errors that APIError wraps to describe why a request failed.
+var (
+	ErrNotFound    = data.ErrNotFound
+	ErrForbidden   = data.ErrForbidden
+	ErrRateLimited = data.ErrRateLimited
+	ErrServer      = data.ErrServer
+)
+
+// NewAPIGetter returns a Getter that uses the given go-gh clients. Build the
+// clients with NewRateLimitTransport as their transport to wait out rate
+// limits and retry gateway errors.
+func NewAPIGetter(gqlClient *api.GraphQLClient, restClient *api.RESTClient) *APIGetter {
+	return data.NewAPIGetter(gqlClient, restClient)
+}
+
+// NewRateLimitTransport wraps base, or http.DefaultTransport when base is
+// nil, with the rate limit and retry handling used by the extension.
+func NewRateLimitTransport(base http.RoundTripper) http.RoundTripper {
+	return data.NewRateLimitTransport(base)
+}
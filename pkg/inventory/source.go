@@ -0,0 +1,223 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Item is a secret or variable listed by a SecretSource. Value is only set
+// for variables.
+type Item struct {
+	Name       string
+	Visibility string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Value      string
+}
+
+// SecretSource describes an app that stores secrets or variables at the
+// organization and repository level, where organization entries with
+// selected visibility are scoped to a list of repositories. A Collector
+// gathers from every registered source whose App is in its AppSet.
+type SecretSource interface {
+	// App selects the source in an AppSet.
+	App() App
+	// SecretType is reported in exports and when a request fails.
+	SecretType() string
+	OrgItems(ctx context.Context, g Getter, owner string) ([]Item, error)
+	RepoItems(ctx context.Context, g Getter, owner string, repo string) ([]Item, error)
+	ScopedRepositories(ctx context.Context, g Getter, owner string, name string) ([]ScopedRepository, error)
+}
+
+// EnvironmentSource is a SecretSource that also stores entries at the
+// environment level. They are gathered when EnvironmentApp is in the
+// AppSet, which may differ from App, as Actions environment secrets are
+// selected by AppEnvironments.
+type EnvironmentSource interface {
+	SecretSource
+	EnvironmentApp() App
+	EnvironmentItems(ctx context.Context, g Getter, owner string, repo string, environment string) ([]Item, error)
+}
+
+var (
+	sourcesMu sync.RWMutex
+	sources   = []SecretSource{
+		NewEnvironmentSource(AppActions, AppEnvironments, "Actions", secretItem,
+			Getter.GetOrgActionSecrets, Getter.GetRepoActionSecrets, Getter.GetScopedOrgActionSecrets,
+			Getter.GetEnvironmentSecrets),
+		NewSource(AppDependabot, "Dependabot", secretItem,
+			Getter.GetOrgDependabotSecrets, Getter.GetRepoDependabotSecrets, Getter.GetScopedOrgDependabotSecrets),
+		NewSource(AppCodespaces, "Codespaces", secretItem,
+			Getter.GetOrgCodespacesSecrets, Getter.GetRepoCodespacesSecrets, Getter.GetScopedOrgCodespacesSecrets),
+		NewEnvironmentSource(AppVariables, AppVariables, "Variables", variableItem,
+			Getter.GetOrgActionVariables, Getter.GetRepoActionVariables, Getter.GetScopedOrgActionVariables,
+			Getter.GetEnvironmentVariables),
+	}
+	allApps = []App{AppActions, AppCodespaces, AppDependabot, AppEnvironments, AppVariables}
+)
+
+// RegisterSource adds s to the sources gathered by every Collector, after
+// those already registered, and makes its apps known app names. An app that
+// is already known is an error, so two sources never share an app.
+func RegisterSource(s SecretSource) error {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+
+	apps := sourceApps(s)
+	for _, app := range apps {
+		if slices.Contains(allApps, app) {
+			return fmt.Errorf("app %q is already registered", app)
+		}
+	}
+	sources = append(sources, s)
+	for _, app := range apps {
+		if !slices.Contains(allApps, app) {
+			allApps = append(allApps, app)
+		}
+	}
+	return nil
+}
+
+// sourceApps returns the apps that select entries of s.
+func sourceApps(s SecretSource) []App {
+	apps := []App{s.App()}
+	if env, ok := s.(EnvironmentSource); ok && env.EnvironmentApp() != s.App() {
+		apps = append(apps, env.EnvironmentApp())
+	}
+	return apps
+}
+
+// Sources returns the registered sources in the order they are collected.
+func Sources() []SecretSource {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	return append([]SecretSource(nil), sources...)
+}
+
+// source is a SecretSource built from Getter methods.
+type source[T any] struct {
+	app        App
+	secretType string
+	item       func(T) Item
+	org        func(Getter, context.Context, string) ([]T, error)
+	repo       func(Getter, context.Context, string, string) ([]T, error)
+	scoped     func(Getter, context.Context, string, string) ([]ScopedRepository, error)
+}
+
+// environmentSource is a source that also lists environment level entries.
+type environmentSource[T any] struct {
+	*source[T]
+	environmentApp App
+	env            func(Getter, context.Context, string, string, string) ([]T, error)
+}
+
+// NewSource returns a SecretSource that lists entries with org, repo and
+// scoped, typically Getter method expressions such as
+// Getter.GetOrgActionSecrets, and converts each entry with item.
+func NewSource[T any](
+	app App,
+	secretType string,
+	item func(T) Item,
+	org func(Getter, context.Context, string) ([]T, error),
+	repo func(Getter, context.Context, string, string) ([]T, error),
+	scoped func(Getter, context.Context, string, string) ([]ScopedRepository, error),
+) SecretSource {
+	return &source[T]{
+		app:        app,
+		secretType: secretType,
+		item:       item,
+		org:        org,
+		repo:       repo,
+		scoped:     scoped,
+	}
+}
+
+// NewEnvironmentSource returns a SecretSource like NewSource that also lists
+// environment level entries with env, such as Getter.GetEnvironmentSecrets,
+// when environmentApp is collected.
+func NewEnvironmentSource[T any](
+	app App,
+	environmentApp App,
+	secretType string,
+	item func(T) Item,
+	org func(Getter, context.Context, string) ([]T, error),
+	repo func(Getter, context.Context, string, string) ([]T, error),
+	scoped func(Getter, context.Context, string, string) ([]ScopedRepository, error),
+	env func(Getter, context.Context, string, string, string) ([]T, error),
+) EnvironmentSource {
+	return &environmentSource[T]{
+		source:         NewSource(app, secretType, item, org, repo, scoped).(*source[T]),
+		environmentApp: environmentApp,
+		env:            env,
+	}
+}
+
+func (s *source[T]) App() App {
+	return s.app
+}
+
+func (s *source[T]) SecretType() string {
+	return s.secretType
+}
+
+func (s *source[T]) OrgItems(ctx context.Context, g Getter, owner string) ([]Item, error) {
+	entries, err := s.org(g, ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return s.items(entries), nil
+}
+
+func (s *source[T]) RepoItems(ctx context.Context, g Getter, owner string, repo string) ([]Item, error) {
+	entries, err := s.repo(g, ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	return s.items(entries), nil
+}
+
+func (s *source[T]) ScopedRepositories(ctx context.Context, g Getter, owner string, name string) ([]ScopedRepository, error) {
+	return s.scoped(g, ctx, owner, name)
+}
+
+func (s *environmentSource[T]) EnvironmentApp() App {
+	return s.environmentApp
+}
+
+func (s *environmentSource[T]) EnvironmentItems(ctx context.Context, g Getter, owner string, repo string, environment string) ([]Item, error) {
+	entries, err := s.env(g, ctx, owner, repo, environment)
+	if err != nil {
+		return nil, err
+	}
+	return s.items(entries), nil
+}
+
+func (s *source[T]) items(entries []T) []Item {
+	items := make([]Item, 0, len(entries))
+	for _, entry := range entries {
+		items = append(items, s.item(entry))
+	}
+	return items
+}
+
+func secretItem(secret Secret) Item {
+	return Item{
+		Name:       secret.Name,
+		Visibility: secret.Visibility,
+		CreatedAt:  secret.CreatedAt,
+		UpdatedAt:  secret.UpdatedAt,
+	}
+}
+
+func variableItem(variable Variable) Item {
+	return Item{
+		Name:       variable.Name,
+		Visibility: variable.Visibility,
+		CreatedAt:  variable.CreatedAt,
+		UpdatedAt:  variable.UpdatedAt,
+		Value:      variable.Value,
+	}
+}
@@ -0,0 +1,23 @@
+package inventory_test
+
+import (
+	"testing"
+
+	"github.com/katiem0/gh-export-secrets/pkg/inventory"
+)
+
+func TestRegisterSourceRejectsKnownApp(t *testing.T) {
+	for _, app := range []inventory.App{inventory.AppActions, inventory.AppEnvironments} {
+		before := len(inventory.Sources())
+		source := inventory.NewSource(app, "Duplicate", func(secret inventory.Secret) inventory.Item {
+			return inventory.Item{Name: secret.Name}
+		}, inventory.Getter.GetOrgActionSecrets, inventory.Getter.GetRepoActionSecrets, inventory.Getter.GetScopedOrgActionSecrets)
+
+		if err := inventory.RegisterSource(source); err == nil {
+			t.Errorf("RegisterSource(%s) error = nil, want an error for a known app", app)
+		}
+		if got := len(inventory.Sources()); got != before {
+			t.Errorf("RegisterSource(%s) left %d sources, want %d", app, got, before)
+		}
+	}
+}
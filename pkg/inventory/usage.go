@@ -0,0 +1,350 @@
+package inventory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/katiem0/gh-export-secrets/internal/data"
+	"github.com/katiem0/gh-export-secrets/internal/usage"
+	"go.uber.org/zap"
+)
+
+// Reference is a use of a secret or variable in a repository file.
+type Reference = usage.Reference
+
+// repoUsage holds the files of a repository that reference secrets.
+type repoUsage struct {
+	workflows []*usage.Workflow
+	// dependabotConfig is the path of the Dependabot configuration, if
+	// the repository has one and Dependabot secrets are collected.
+	dependabotConfig string
+	// dependabotEnabled is set when the repository has a Dependabot
+	// configuration or Dependabot security updates enabled.
+	dependabotEnabled bool
+	registries        []usage.Registry
+	// devcontainerRefs are the secrets recommended by dev container
+	// configurations, when Codespaces secrets are collected.
+	devcontainerRefs []Reference
+}
+
+// scanUsage fetches and parses the files of every repository that reference
+// secrets, using up to Concurrency requests at a time, and returns them
+// keyed by repository name.
+func (c *Collector) scanUsage(ctx context.Context, allRepos []data.RepoInfo) (map[string]*repoUsage, error) {
+	contents, ok := c.Getter.(ContentGetter)
+	if !ok {
+		return nil, errNoContentGetter
+	}
+	results := make([]*repoUsage, len(allRepos))
+	errs := make([]error, len(allRepos))
+
+	sem := make(chan struct{}, max(c.Concurrency, 1))
+	var wg sync.WaitGroup
+
+	for i, repo := range allRepos {
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = c.scanRepoUsage(ctx, contents, repo.Name)
+		}()
+	}
+	wg.Wait()
+
+	usages := map[string]*repoUsage{}
+	for i, repo := range allRepos {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		usages[repo.Name] = results[i]
+	}
+	return usages, nil
+}
+
+// scanRepoUsage fetches and parses the files of repo that reference secrets.
+func (c *Collector) scanRepoUsage(ctx context.Context, contents ContentGetter, repo string) (*repoUsage, error) {
+	workflows, err := c.repoWorkflows(ctx, contents, repo)
+	if err != nil {
+		return nil, err
+	}
+	result := &repoUsage{workflows: workflows}
+
+	if c.Apps.Has(AppDependabot) {
+		if result.dependabotConfig, result.registries, err = c.repoRegistries(ctx, contents, repo); err != nil {
+			return nil, err
+		}
+		if result.dependabotEnabled, err = c.repoRunsDependabot(ctx, repo, result); err != nil {
+			return nil, err
+		}
+	}
+	if c.Apps.Has(AppCodespaces) {
+		if result.devcontainerRefs, err = c.repoDevcontainerSecrets(ctx, contents, repo); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// repoWorkflows returns the parsed workflows of repo.
+func (c *Collector) repoWorkflows(ctx context.Context, contents ContentGetter, repo string) ([]*usage.Workflow, error) {
+	zap.S().Debugf("Scanning workflows of %s/%s", c.Owner, repo)
+
+	entries, err := contents.GetDirectoryContents(ctx, c.Owner, repo, usage.WorkflowsDir)
+	if err = c.skip(repo, "Workflows", err); err != nil {
+		return nil, err
+	}
+
+	var workflows []*usage.Workflow
+	for _, entry := range entries {
+		if entry.Type != "file" || !usage.IsWorkflowFile(entry.Name) {
+			continue
+		}
+		content, err := contents.GetFileContents(ctx, c.Owner, repo, entry.Path)
+		if err = c.skip(repo, "Workflows", err); err != nil {
+			return nil, err
+		}
+		if content == nil {
+			continue
+		}
+
+		workflow, err := usage.ParseWorkflow(entry.Path, content)
+		if err != nil {
+			zap.S().Warnf("Skipping workflow that could not be parsed in %s/%s: %v", c.Owner, repo, err)
+			continue
+		}
+		workflows = append(workflows, workflow)
+	}
+	return workflows, nil
+}
+
+// repoRunsDependabot reports whether Dependabot is enabled for repo, either
+// by its configuration or by security updates in its settings. Settings are
+// only read when the Getter implements DependabotGetter and a workflow that
+// Dependabot would trigger has no configuration to account for it.
+func (c *Collector) repoRunsDependabot(ctx context.Context, repo string, scanned *repoUsage) (bool, error) {
+	if scanned.dependabotConfig != "" {
+		return true, nil
+	}
+	settings, ok := c.Getter.(DependabotGetter)
+	if !ok || !slices.ContainsFunc(scanned.workflows, dependabotTriggers) {
+		return false, nil
+	}
+
+	enabled, err := settings.GetDependabotSecurityUpdates(ctx, c.Owner, repo)
+	if err = c.skip(repo, "Dependabot", err); err != nil {
+		return false, err
+	}
+	return enabled, nil
+}
+
+// repoRegistries returns the path of the Dependabot configuration of repo
+// and the registries it declares. The path is empty when the repository has
+// no configuration.
+func (c *Collector) repoRegistries(ctx context.Context, contents ContentGetter, repo string) (string, []usage.Registry, error) {
+	entries, err := contents.GetDirectoryContents(ctx, c.Owner, repo, usage.DependabotDir)
+	if err = c.skip(repo, "Dependabot", err); err != nil {
+		return "", nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.Type != "file" || !usage.IsDependabotFile(entry.Name) {
+			continue
+		}
+		content, err := contents.GetFileContents(ctx, c.Owner, repo, entry.Path)
+		if err = c.skip(repo, "Dependabot", err); err != nil {
+			return "", nil, err
+		}
+		if content == nil {
+			return "", nil, nil
+		}
+
+		registries, err := usage.ParseDependabot(entry.Path, content)
+		if err != nil {
+			zap.S().Warnf("Skipping Dependabot configuration that could not be parsed in %s/%s: %v", c.Owner, repo, err)
+			return "", nil, nil
+		}
+		return entry.Path, registries, nil
+	}
+	return "", nil, nil
+}
+
+// repoDevcontainerSecrets returns the secrets recommended by the dev
+// container configurations of repo, in .devcontainer and its immediate
+// subdirectories.
+func (c *Collector) repoDevcontainerSecrets(ctx context.Context, contents ContentGetter, repo string) ([]Reference, error) {
+	entries, err := contents.GetDirectoryContents(ctx, c.Owner, repo, usage.DevcontainerDir)
+	if err = c.skip(repo, "Codespaces", err); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		switch {
+		case entry.Type == "file" && entry.Name == usage.DevcontainerFile:
+			paths = append(paths, entry.Path)
+		case entry.Type == "dir":
+			subEntries, err := contents.GetDirectoryContents(ctx, c.Owner, repo, entry.Path)
+			if err = c.skip(repo, "Codespaces", err); err != nil {
+				return nil, err
+			}
+			for _, subEntry := range subEntries {
+				if subEntry.Type == "file" && subEntry.Name == usage.DevcontainerFile {
+					paths = append(paths, subEntry.Path)
+				}
+			}
+		}
+	}
+
+	var refs []Reference
+	for _, path := range paths {
+		content, err := contents.GetFileContents(ctx, c.Owner, repo, path)
+		if err = c.skip(repo, "Codespaces", err); err != nil {
+			return nil, err
+		}
+		if content == nil {
+			continue
+		}
+
+		devcontainerRefs, err := usage.ParseDevcontainer(path, content)
+		if err != nil {
+			zap.S().Warnf("Skipping dev container configuration that could not be parsed in %s/%s: %v", c.Owner, repo, err)
+			continue
+		}
+		refs = append(refs, devcontainerRefs...)
+	}
+	return refs, nil
+}
+
+// usageIndex holds the references found by scanUsage keyed by repository
+// name, and the reusable workflow calls between repositories.
+type usageIndex struct {
+	workflowRefs     map[string][]Reference
+	registryRefs     map[string][]Reference
+	devcontainerRefs map[string][]Reference
+	// dependabotRefs are the references that read Dependabot secrets: those
+	// of registries, and of workflows that Dependabot's pull requests
+	// trigger in repositories with a Dependabot configuration.
+	dependabotRefs map[string][]Reference
+	// dependabotWorkflows holds the workflows Dependabot triggers
+	dependabotWorkflows map[string][]*usage.Workflow
+	graph               *callGraph
+}
+
+func newUsageIndex(owner string, usages map[string]*repoUsage) *usageIndex {
+	index := &usageIndex{
+		workflowRefs:        map[string][]Reference{},
+		registryRefs:        map[string][]Reference{},
+		devcontainerRefs:    map[string][]Reference{},
+		dependabotRefs:      map[string][]Reference{},
+		dependabotWorkflows: map[string][]*usage.Workflow{},
+	}
+	workflows := map[string][]*usage.Workflow{}
+	for repo, scanned := range usages {
+		workflows[repo] = scanned.workflows
+		for _, workflow := range scanned.workflows {
+			index.workflowRefs[repo] = append(index.workflowRefs[repo], workflow.References...)
+		}
+		for _, registry := range scanned.registries {
+			index.registryRefs[repo] = append(index.registryRefs[repo], registry.References...)
+		}
+		index.devcontainerRefs[repo] = scanned.devcontainerRefs
+		index.dependabotWorkflows[repo] = triggeredByDependabot(scanned)
+
+		index.dependabotRefs[repo] = slices.Clone(index.registryRefs[repo])
+		for _, workflow := range index.dependabotWorkflows[repo] {
+			index.dependabotRefs[repo] = append(index.dependabotRefs[repo], workflow.References...)
+		}
+	}
+	index.graph = newCallGraph(owner, workflows)
+	return index
+}
+
+// triggeredByDependabot returns the workflows of scanned that Dependabot's
+// pull requests trigger, when Dependabot is enabled.
+func triggeredByDependabot(scanned *repoUsage) []*usage.Workflow {
+	if !scanned.dependabotEnabled {
+		return nil
+	}
+	var workflows []*usage.Workflow
+	for _, workflow := range scanned.workflows {
+		if dependabotTriggers(workflow) {
+			workflows = append(workflows, workflow)
+		}
+	}
+	return workflows
+}
+
+// dependabotTriggers reports whether Dependabot's pull requests trigger
+// workflow.
+func dependabotTriggers(workflow *usage.Workflow) bool {
+	return slices.ContainsFunc(workflow.Events, func(event string) bool { return slices.Contains(dependabotEvents, event) })
+}
+
+// annotateUsage sets Referenced and ReferencedBy on export from the
+// references found in its repository, and IndirectConsumers from the
+// reusable workflows its workflows pass Actions secrets to. Dependabot
+// secrets are referenced by Dependabot registries and by the workflows
+// Dependabot triggers, and Codespaces secrets by dev container
+// configurations. Only secret types that
+// files can reference are annotated.
+func annotateUsage(export *data.SecretExport, index *usageIndex) {
+	var refContext string
+	var refs []Reference
+	switch export.SecretType {
+	case "Actions":
+		refContext = usage.ContextSecrets
+		refs = index.workflowRefs[export.RepositoryName]
+	case "Dependabot":
+		refContext = usage.ContextSecrets
+		refs = index.dependabotRefs[export.RepositoryName]
+	case "Codespaces":
+		refContext = usage.ContextSecrets
+		refs = index.devcontainerRefs[export.RepositoryName]
+	case "Variables":
+		refContext = usage.ContextVars
+		refs = index.workflowRefs[export.RepositoryName]
+	default:
+		return
+	}
+
+	referenced := false
+	referencedBy := []string{}
+	for _, ref := range refs {
+		if ref.Context != refContext || !strings.EqualFold(ref.Name, export.SecretName) {
+			continue
+		}
+		if export.SecretLevel == "Environment" && !inEnvironment(ref, export.SecretAccess) {
+			continue
+		}
+		referenced = true
+		if !slices.Contains(referencedBy, ref.Path) {
+			referencedBy = append(referencedBy, ref.Path)
+		}
+	}
+	slices.Sort(referencedBy)
+
+	export.Referenced = &referenced
+	export.ReferencedBy = referencedBy
+
+	// Environment secrets cannot be passed, as a job that calls a reusable
+	// workflow cannot deploy to an environment
+	if export.SecretType == "Actions" && export.SecretLevel != "Environment" && export.RepositoryName != "" {
+		export.IndirectConsumers = index.graph.consumers(export.RepositoryName, export.SecretName)
+	}
+}
+
+// inEnvironment reports whether ref can read the secrets of environment.
+// A job whose environment is an expression may deploy to any environment.
+func inEnvironment(ref Reference, environment string) bool {
+	if strings.Contains(ref.Environment, "${{") {
+		return true
+	}
+	return ref.Environment != "" && strings.EqualFold(ref.Environment, environment)
+}